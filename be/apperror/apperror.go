@@ -0,0 +1,138 @@
+// Package apperror defines the errors handlers report to API clients. Each
+// carries a stable machine-readable code, the HTTP status it is served with and
+// a message that is safe to show, while the underlying cause is only logged.
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Error is an application error. Use the predefined errors in codes.go and
+// attach a cause with Wrap, per-field details with WithFields or a
+// representation of the resource with WithData.
+type Error struct {
+	// Code identifies the error to clients, e.g. INVENTORY_NOT_FOUND
+	Code string
+	// Status is the HTTP status the error is served with
+	Status int
+	// Message is shown to the user
+	Message string
+	// Fields maps request fields to what is wrong with them
+	Fields map[string]string
+	// Data is sent as the response data, such as the current version of a resource
+	Data interface{}
+	// Cause is the underlying error, logged but never sent to the client
+	Cause error
+}
+
+// codes lists every predefined error in the order they are declared
+var codes []*Error
+
+func newError(code string, status int, message string) *Error {
+	e := &Error{Code: code, Status: status, Message: message}
+	codes = append(codes, e)
+	return e
+}
+
+// Codes returns every predefined error
+func Codes() []*Error {
+	return slices.Clone(codes)
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Code + ": " + e.Cause.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether target is an Error with the same code, so wrapped copies
+// still match the predefined error
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e caused by cause
+func (e *Error) Wrap(cause error) *Error {
+	copied := *e
+	copied.Cause = cause
+	return &copied
+}
+
+// WithFields returns a copy of e with fields added to its field details
+func (e *Error) WithFields(fields map[string]string) *Error {
+	copied := *e
+	copied.Fields = maps.Clone(e.Fields)
+	if copied.Fields == nil {
+		copied.Fields = make(map[string]string, len(fields))
+	}
+	maps.Copy(copied.Fields, fields)
+	return &copied
+}
+
+// WithData returns a copy of e that answers with data
+func (e *Error) WithData(data interface{}) *Error {
+	copied := *e
+	copied.Data = data
+	return &copied
+}
+
+// From returns err as an Error. Missing rows become NOT_FOUND, validation
+// failures VALIDATION_FAILED and anything unexpected INTERNAL.
+func From(err error) *Error {
+	var appErr *Error
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NotFound.Wrap(err)
+	case errors.As(err, &validator.ValidationErrors{}):
+		return Validation.Wrap(err)
+	default:
+		return Internal.Wrap(err)
+	}
+}
+
+// FromBinding returns the error for a request body that could not be bound:
+// VALIDATION_FAILED when it broke a binding rule and INVALID_JSON otherwise
+func FromBinding(err error) *Error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validator.ValidationErrors{}):
+		return Validation.Wrap(err)
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return InvalidJSON.Wrap(err)
+	default:
+		return Validation.Wrap(err)
+	}
+}
+
+// WhenNotFound returns notFound caused by err when err is a missing row, and err otherwise
+func WhenNotFound(err error, notFound *Error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFound.Wrap(err)
+	}
+	return err
+}
+
+// WhenDuplicate returns conflict caused by err when err breaks a unique index, and err otherwise
+func WhenDuplicate(err error, conflict *Error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return conflict.Wrap(err)
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package apperror
+
+import "net/http"
+
+// Codes are part of the API: clients match on them, so never change or reuse one.
+// Keep the list in the README in sync. Messages are translated by the i18n
+// catalogs under error.<code>; the ones here are the English defaults.
+var (
+	Validation  = newError("VALIDATION_FAILED", http.StatusBadRequest, "Invalid input")
+	InvalidJSON = newError("INVALID_JSON", http.StatusBadRequest, "Request body is not valid JSON")
+	NotFound    = newError("NOT_FOUND", http.StatusNotFound, "Data not found")
+	Internal    = newError("INTERNAL", http.StatusInternalServerError, "Something went wrong, please try again later")
+
+	PreconditionRequired = newError("PRECONDITION_REQUIRED", http.StatusPreconditionRequired, "If-Match header with the resource's ETag is required")
+	PreconditionFailed   = newError("PRECONDITION_FAILED", http.StatusPreconditionFailed, "The resource has changed since you read it")
+)
+
+// Authentication and authorization
+var (
+	TokenRequired     = newError("TOKEN_REQUIRED", http.StatusUnauthorized, "Authorization token required")
+	AuthSchemeInvalid = newError("AUTH_SCHEME_INVALID", http.StatusUnauthorized, "Authorization must be a Bearer token")
+	TokenInvalid      = newError("TOKEN_INVALID", http.StatusUnauthorized, "Invalid token")
+	TokenExpired      = newError("TOKEN_EXPIRED", http.StatusUnauthorized, "Token has expired")
+	RoleChanged       = newError("ROLE_CHANGED", http.StatusUnauthorized, "Role has changed, please sign in again")
+	SessionRevoked    = newError("SESSION_REVOKED", http.StatusUnauthorized, "Session has been revoked")
+	UserInactive      = newError("USER_INACTIVE", http.StatusUnauthorized, "User is no longer active")
+	APIKeyInvalid     = newError("API_KEY_INVALID", http.StatusUnauthorized, "Invalid or revoked API key")
+	PermissionDenied  = newError("PERMISSION_DENIED", http.StatusForbidden, "You do not have permission to do this")
+	ScopeMissing      = newError("SCOPE_MISSING", http.StatusForbidden, "API key is missing a required scope")
+	SessionRequired   = newError("SESSION_REQUIRED", http.StatusForbidden, "This needs a signed-in session, not an API key")
+)
+
+// Sign-in and sessions
+var (
+	LoginRateLimited    = newError("LOGIN_RATE_LIMITED", http.StatusTooManyRequests, "Too many sign-in requests, please try again later")
+	LoginTokenRequired  = newError("LOGIN_TOKEN_REQUIRED", http.StatusBadRequest, "Token is required")
+	LoginTokenInvalid   = newError("LOGIN_TOKEN_INVALID", http.StatusUnauthorized, "Invalid, expired or already used token")
+	LoginCodeInvalid    = newError("LOGIN_CODE_INVALID", http.StatusUnauthorized, "Invalid or expired code")
+	LoginCodeLocked     = newError("LOGIN_CODE_LOCKED", http.StatusTooManyRequests, "Too many wrong codes, please request a new one")
+	RefreshTokenInvalid = newError("REFRESH_TOKEN_INVALID", http.StatusUnauthorized, "Invalid or expired refresh token")
+	UserDeactivated     = newError("USER_DEACTIVATED", http.StatusForbidden, "User has been deactivated")
+	InvitationRequired  = newError("INVITATION_REQUIRED", http.StatusForbidden, "An invitation is required to sign up")
+)
+
+// Users
+var (
+	UserNotFound      = newError("USER_NOT_FOUND", http.StatusNotFound, "User not found")
+	UserExists        = newError("USER_EXISTS", http.StatusConflict, "User already exists")
+	InvitationPending = newError("INVITATION_PENDING", http.StatusConflict, "Another organization has already invited this email")
+	RoleUnknown       = newError("ROLE_UNKNOWN", http.StatusBadRequest, "Unknown role")
+	CannotChangeSelf  = newError("CANNOT_CHANGE_SELF", http.StatusForbidden, "You cannot change your own account")
+	LocaleUnsupported = newError("LOCALE_UNSUPPORTED", http.StatusBadRequest, "Language is not supported")
+)
+
+// Inventory and recipes
+var (
+	InventoryNotFound  = newError("INVENTORY_NOT_FOUND", http.StatusNotFound, "Inventory item not found")
+	InventoryNameTaken = newError("INVENTORY_NAME_TAKEN", http.StatusConflict, "Another inventory item already has this name")
+	MergeSameItem      = newError("MERGE_SAME_ITEM", http.StatusBadRequest, "An inventory item cannot be merged into itself")
+	MergeUnitMismatch  = newError("MERGE_UNIT_MISMATCH", http.StatusUnprocessableEntity, "The items are stocked in units that cannot be combined")
+	RecipeNotFound     = newError("RECIPE_NOT_FOUND", http.StatusNotFound, "Recipe not found")
+	IngredientNotFound = newError("INGREDIENT_NOT_FOUND", http.StatusUnprocessableEntity, "Ingredient is not in the inventory")
+	UnitIncompatible   = newError("UNIT_INCOMPATIBLE", http.StatusUnprocessableEntity, "Ingredient unit cannot be priced from the inventory item")
+)
+
+// API keys and the email outbox
+var (
+	APIKeyNotFound    = newError("API_KEY_NOT_FOUND", http.StatusNotFound, "API key not found")
+	ScopeNotAllowed   = newError("SCOPE_NOT_ALLOWED", http.StatusBadRequest, "Scope is not available to you")
+	EmailNotFound     = newError("EMAIL_NOT_FOUND", http.StatusNotFound, "Email not found")
+	EmailAlreadySent  = newError("EMAIL_ALREADY_SENT", http.StatusConflict, "Email has already been sent")
+	EmailNotRetryable = newError("EMAIL_NOT_RETRYABLE", http.StatusConflict, "Sign-in emails expire and cannot be retried")
+)
@@ -0,0 +1,410 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// DefaultFile is read when it exists and no other file is given with -config
+const DefaultFile = ".env"
+
+// Config is the application configuration
+type Config struct {
+	// Environment is production or development; development relaxes the
+	// checks meant for deployed servers
+	Environment string
+	Port        int
+	FrontendURL string
+	InviteOnly  bool
+	LogLevel    slog.Level
+	// MetricsToken protects /metrics; it may only be empty in development
+	MetricsToken string
+	HTTP         HTTP
+	Tracing      Tracing
+	Database     Database
+	JWT          JWT
+	Mail         Mail
+}
+
+// HTTP configures the HTTP server. ShutdownTimeout bounds how long in-flight
+// requests and background workers get to finish after SIGTERM.
+type HTTP struct {
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+}
+
+// Tracing configures where OpenTelemetry spans are exported. Exporter is
+// none, stdout or otlp; the OTLP exporter falls back to the standard
+// OTEL_EXPORTER_OTLP_* variables when Endpoint is empty.
+type Tracing struct {
+	Exporter    string
+	Endpoint    string
+	ServiceName string
+	SampleRatio float64
+}
+
+// Database configures the database connection. Driver is postgres or sqlite;
+// SQLite only needs Path, and for Postgres URL takes precedence over the
+// separate fields.
+type Database struct {
+	Driver   string
+	Path     string
+	URL      string
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Name     string
+	// SlowQueryThreshold is how long a query may take before it is logged
+	SlowQueryThreshold time.Duration
+}
+
+// JWT configures the access token signing keys
+type JWT struct {
+	KeyDir      string
+	KeyRotation time.Duration
+}
+
+// Mail configures the email transport
+type Mail struct {
+	Transport      string
+	OutboxDir      string
+	SMTPHost       string
+	SMTPPort       int
+	SMTPSenderName string
+	SMTPEmail      string
+	SMTPPassword   string
+}
+
+// setting is a configuration value, named by its environment variable. Every
+// setting can also be passed as a flag named after it, e.g. -smtp-host.
+type setting struct {
+	env      string
+	fallback string
+	usage    string
+}
+
+var settings = []setting{
+	{"APP_ENV", "production", "production or development"},
+	{"PORT", "8080", "HTTP port to listen on"},
+	{"FRONTEND_URL", "", "frontend page that magic links point to"},
+	{"INVITE_ONLY", "false", "only let existing users and invited emails sign in"},
+	{"LOG_LEVEL", "info", "debug, info, warn or error"},
+	{"METRICS_TOKEN", "", "bearer token required to read /metrics, open when empty in development"},
+	{"HTTP_READ_TIMEOUT", "15s", "maximum time to read a request, including its body"},
+	{"HTTP_WRITE_TIMEOUT", "30s", "maximum time to write a response"},
+	{"HTTP_IDLE_TIMEOUT", "120s", "how long idle keep-alive connections stay open"},
+	{"SHUTDOWN_TIMEOUT", "30s", "how long to wait for requests and workers to finish on shutdown"},
+	{"TRACING_EXPORTER", "none", "none, stdout or otlp"},
+	{"TRACING_ENDPOINT", "", "OTLP/HTTP collector URL such as http://localhost:4318"},
+	{"TRACING_SERVICE_NAME", "be-test", "service name reported with every span"},
+	{"TRACING_SAMPLE_RATIO", "1", "share of new traces that are recorded, from 0 to 1"},
+	{"DB_DRIVER", "postgres", "postgres or sqlite"},
+	{"DB_PATH", "", "SQLite database file"},
+	{"DATABASE_URL", "", "Postgres connection URL, overrides the DB_* settings"},
+	{"DB_HOST", "localhost", "database host"},
+	{"DB_PORT", "5432", "database port"},
+	{"DB_USER", "", "database user"},
+	{"DB_PASSWORD", "", "database password"},
+	{"DB_NAME", "", "database name"},
+	{"DB_SLOW_QUERY_THRESHOLD", "200ms", "queries slower than this are logged"},
+	{"JWT_KEY_DIR", "", "directory of the token signing keys, keys are kept in memory when empty"},
+	{"JWT_KEY_ROTATION", "720h", "how often a new signing key is generated"},
+	{"MAIL_TRANSPORT", "smtp", "smtp or outbox"},
+	{"MAIL_OUTBOX_DIR", "", "directory the outbox transport writes .eml files to"},
+	{"SMTP_HOST", "", "SMTP server host"},
+	{"SMTP_PORT", "587", "SMTP server port"},
+	{"SMTP_SENDER_NAME", "", "display name of the sender"},
+	{"SMTP_EMAIL", "", "sender address and SMTP user"},
+	{"SMTP_PASSWORD", "", "SMTP password"},
+}
+
+// flagName turns SMTP_HOST into smtp-host
+func flagName(env string) string {
+	return strings.ReplaceAll(strings.ToLower(env), "_", "-")
+}
+
+// Load reads the configuration from, in increasing precedence, the defaults,
+// a dotenv file, the environment and command-line flags. The file is
+// .env unless -config names another one; a missing .env is not an error.
+// Load returns the arguments left after the flags, such as a subcommand.
+func Load(args []string) (Config, []string, error) {
+	fs := flag.NewFlagSet("be-test", flag.ContinueOnError)
+	file := fs.String("config", "", "dotenv file to read settings from (default "+DefaultFile+" when present)")
+	flags := map[string]*string{}
+	for _, s := range settings {
+		flags[s.env] = fs.String(flagName(s.env), "", s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	values := map[string]string{}
+	for _, s := range settings {
+		values[s.env] = s.fallback
+	}
+
+	path := *file
+	if path == "" {
+		path = DefaultFile
+	}
+	fileValues, err := godotenv.Read(path)
+	if err != nil && (*file != "" || !errors.Is(err, os.ErrNotExist)) {
+		return Config{}, nil, fmt.Errorf("reading config file %s: %w", path, err)
+	}
+	for key, value := range fileValues {
+		if value != "" {
+			values[key] = value
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && value != "" {
+			values[s.env] = value
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if flagName(s.env) == f.Name {
+				values[s.env] = *flags[s.env]
+			}
+		}
+	})
+
+	cfg, err := parse(values)
+	return cfg, fs.Args(), err
+}
+
+// parse converts the raw settings, reporting every malformed value at once
+func parse(values map[string]string) (Config, error) {
+	var errs []error
+	integer := func(key string) int {
+		n, err := strconv.Atoi(values[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s must be a number, got %q", key, values[key]))
+		}
+		return n
+	}
+	boolean := func(key string) bool {
+		b, err := strconv.ParseBool(values[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s must be true or false, got %q", key, values[key]))
+		}
+		return b
+	}
+	float := func(key string) float64 {
+		f, err := strconv.ParseFloat(values[key], 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s must be a number, got %q", key, values[key]))
+		}
+		return f
+	}
+	level := func(key string) slog.Level {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(values[key])); err != nil {
+			errs = append(errs, fmt.Errorf("%s must be debug, info, warn or error, got %q", key, values[key]))
+		}
+		return l
+	}
+	duration := func(key string) time.Duration {
+		d, err := time.ParseDuration(values[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s must be a duration such as 720h, got %q", key, values[key]))
+		}
+		return d
+	}
+
+	cfg := Config{
+		Environment:  values["APP_ENV"],
+		Port:         integer("PORT"),
+		FrontendURL:  values["FRONTEND_URL"],
+		InviteOnly:   boolean("INVITE_ONLY"),
+		LogLevel:     level("LOG_LEVEL"),
+		MetricsToken: values["METRICS_TOKEN"],
+		HTTP: HTTP{
+			ReadTimeout:     duration("HTTP_READ_TIMEOUT"),
+			WriteTimeout:    duration("HTTP_WRITE_TIMEOUT"),
+			IdleTimeout:     duration("HTTP_IDLE_TIMEOUT"),
+			ShutdownTimeout: duration("SHUTDOWN_TIMEOUT"),
+		},
+		Tracing: Tracing{
+			Exporter:    values["TRACING_EXPORTER"],
+			Endpoint:    values["TRACING_ENDPOINT"],
+			ServiceName: values["TRACING_SERVICE_NAME"],
+			SampleRatio: float("TRACING_SAMPLE_RATIO"),
+		},
+		Database: Database{
+			Driver:   values["DB_DRIVER"],
+			Path:     values["DB_PATH"],
+			URL:      values["DATABASE_URL"],
+			Host:     values["DB_HOST"],
+			Port:     integer("DB_PORT"),
+			User:     values["DB_USER"],
+			Password: values["DB_PASSWORD"],
+			Name:     values["DB_NAME"],
+
+			SlowQueryThreshold: duration("DB_SLOW_QUERY_THRESHOLD"),
+		},
+		JWT: JWT{
+			KeyDir:      values["JWT_KEY_DIR"],
+			KeyRotation: duration("JWT_KEY_ROTATION"),
+		},
+		Mail: Mail{
+			Transport:      values["MAIL_TRANSPORT"],
+			OutboxDir:      values["MAIL_OUTBOX_DIR"],
+			SMTPHost:       values["SMTP_HOST"],
+			SMTPPort:       integer("SMTP_PORT"),
+			SMTPSenderName: values["SMTP_SENDER_NAME"],
+			SMTPEmail:      values["SMTP_EMAIL"],
+			SMTPPassword:   values["SMTP_PASSWORD"],
+		},
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// Validate checks the settings the server needs to run
+func (c Config) Validate() error {
+	var errs []error
+	if c.Environment != "production" && c.Environment != "development" {
+		errs = append(errs, fmt.Errorf("APP_ENV must be production or development, got %q", c.Environment))
+	}
+	if c.MetricsToken == "" && c.Environment != "development" {
+		errs = append(errs, errors.New("METRICS_TOKEN is required unless APP_ENV is development"))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be between 1 and 65535, got %d", c.Port))
+	}
+	if u, err := url.Parse(c.FrontendURL); c.FrontendURL == "" || err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("FRONTEND_URL must be an absolute URL, got %q", c.FrontendURL))
+	}
+	if err := c.HTTP.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Database.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.JWT.KeyRotation < time.Hour {
+		errs = append(errs, fmt.Errorf("JWT_KEY_ROTATION must be at least 1h, got %s", c.JWT.KeyRotation))
+	}
+	if err := c.Mail.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks that every timeout is positive
+func (h HTTP) Validate() error {
+	var errs []error
+	for _, timeout := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", h.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", h.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", h.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", h.ShutdownTimeout},
+	} {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", timeout.key, timeout.value))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the exporter and the sample ratio
+func (t Tracing) Validate() error {
+	var errs []error
+	switch t.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if t.Endpoint != "" {
+			if u, err := url.Parse(t.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+				errs = append(errs, fmt.Errorf("TRACING_ENDPOINT must be an absolute URL, got %q", t.Endpoint))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER must be none, stdout or otlp, got %q", t.Exporter))
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1, got %g", t.SampleRatio))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks that a database can be connected to
+func (d Database) Validate() error {
+	switch d.Driver {
+	case "postgres":
+	case "sqlite":
+		if d.Path == "" {
+			return errors.New("DB_PATH is required when DB_DRIVER is sqlite")
+		}
+		return nil
+	default:
+		return fmt.Errorf("DB_DRIVER must be postgres or sqlite, got %q", d.Driver)
+	}
+
+	if d.URL != "" {
+		if _, err := url.Parse(d.URL); err != nil {
+			return fmt.Errorf("DATABASE_URL is not a valid URL: %w", err)
+		}
+		return nil
+	}
+
+	var errs []error
+	for _, required := range []struct{ key, value string }{{"DB_HOST", d.Host}, {"DB_USER", d.User}, {"DB_NAME", d.Name}} {
+		if required.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required unless DATABASE_URL is set", required.key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DSN returns the connection string for a Postgres database
+func (d Database) DSN() string {
+	if d.URL != "" {
+		return d.URL
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     d.Host + ":" + strconv.Itoa(d.Port),
+		Path:     "/" + d.Name,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
+
+// Validate checks that the selected transport is fully configured
+func (m Mail) Validate() error {
+	switch m.Transport {
+	case "outbox":
+		return nil
+	case "smtp":
+		var errs []error
+		if m.SMTPHost == "" {
+			errs = append(errs, errors.New("SMTP_HOST is required when MAIL_TRANSPORT is smtp"))
+		}
+		if m.SMTPEmail == "" {
+			errs = append(errs, errors.New("SMTP_EMAIL is required when MAIL_TRANSPORT is smtp"))
+		}
+		return errors.Join(errs...)
+	default:
+		return fmt.Errorf("MAIL_TRANSPORT must be smtp or outbox, got %q", m.Transport)
+	}
+}
@@ -1,58 +1,65 @@
 package database
 
 import (
-	"be-test/models"
+	"be-test/config"
+	"be-test/metrics"
+	"be-test/tracing"
 	"log"
-	"os"
-	"path/filepath"
-	"runtime"
 
-	"github.com/joho/godotenv"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
+// Init opens the database connection and refuses to run against a schema
+// that is missing migrations
+func Init(cfg config.Database) *gorm.DB {
+	db := Connect(cfg)
 
-// Init initializes the database connection
-func Init() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	if err := CheckMigrations(db); err != nil {
+		log.Fatal(err)
 	}
+	return db
+}
 
-	dsn := "postgres://" + os.Getenv("DB_USER") + ":" + os.Getenv("DB_PASSWORD") + "@" + os.Getenv("DB_HOST") + ":" + os.Getenv("DB_PORT") + "/" + os.Getenv("DB_NAME") + "?sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Connect opens the database connection without checking the schema, for the migrate command
+func Connect(cfg config.Database) *gorm.DB {
+	db, err := gorm.Open(dialector(cfg), &gorm.Config{
+		Logger: newSlowQueryLogger(cfg.SlowQueryThreshold),
+		// Report unique index violations as gorm.ErrDuplicatedKey on every driver
+		TranslateError: true,
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database")
 	}
 
-	DB = db
-
-	// Auto migrate the models
-	DB.AutoMigrate(&models.Inventory{}, &models.User{}, &models.Recipe{})
-}
-
-// Init initializes the database connection
-func InitTest() {
-	// Get the current file's directory
-	_, b, _, _ := runtime.Caller(0)
-	rootPath := filepath.Join(filepath.Dir(b), "..")
-
-	// Load .env from root directory
-	err := godotenv.Load(filepath.Join(rootPath, ".env"))
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	if err := registerTenantCallbacks(db); err != nil {
+		log.Fatal("Failed to register tenant callbacks: ", err)
 	}
+	if err := db.Use(metrics.GORMPlugin{}); err != nil {
+		log.Fatal("Failed to register query metrics: ", err)
+	}
+	if err := db.Use(tracing.GORMPlugin{}); err != nil {
+		log.Fatal("Failed to register query tracing: ", err)
+	}
+	return db
+}
 
-	dsn := "postgres://" + os.Getenv("DB_USER") + ":" + os.Getenv("DB_PASSWORD") + "@" + os.Getenv("DB_HOST") + ":" + os.Getenv("DB_PORT") + "/" + os.Getenv("DB_NAME") + "?sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Failed to connect to database")
+// dialector returns the GORM driver for cfg. SQLite waits for locks instead of
+// failing right away, and enforces foreign keys like Postgres does.
+func dialector(cfg config.Database) gorm.Dialector {
+	if cfg.Driver == "sqlite" {
+		return sqlite.Open(cfg.Path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
 	}
+	return postgres.Open(cfg.DSN())
+}
 
-	DB = db
+// InitTest opens the test database connection and migrates it
+func InitTest(cfg config.Database) *gorm.DB {
+	db := Connect(cfg)
 
-	// Auto migrate the models
-	DB.AutoMigrate(&models.Inventory{}, &models.User{}, &models.Recipe{})
+	if _, err := MigrateUp(db); err != nil {
+		log.Fatal("Failed to migrate the test database: ", err)
+	}
+	return db
 }
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQueryLogger reports failed queries and queries slower than threshold
+// through slog. Queries are logged without their values, which may hold
+// credentials or personal data.
+type slowQueryLogger struct {
+	threshold time.Duration
+}
+
+// newSlowQueryLogger returns a GORM logger for queries slower than threshold
+func newSlowQueryLogger(threshold time.Duration) logger.Interface {
+	return slowQueryLogger{threshold: threshold}
+}
+
+func (l slowQueryLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l slowQueryLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l slowQueryLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l slowQueryLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l slowQueryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "query failed", "sql", sql, "rows", rows, "duration", elapsed, "error", err.Error())
+	case l.threshold > 0 && elapsed > l.threshold:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "slow query", "sql", sql, "rows", rows, "duration", elapsed)
+	}
+}
+
+// ParamsFilter drops the query values so only placeholders are logged
+func (l slowQueryLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
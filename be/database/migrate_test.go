@@ -0,0 +1,195 @@
+package database
+
+import (
+	"be-test/config"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// baselineSchema is the schema AutoMigrate created for the first release,
+// before the versioned migrations replaced it
+const baselineSchema = `
+CREATE TABLE inventories (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    item_name TEXT,
+    quantity DECIMAL,
+    uom TEXT,
+    price_per_qty DECIMAL
+);
+CREATE INDEX idx_inventories_deleted_at ON inventories (deleted_at);
+CREATE TABLE users (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    email TEXT,
+    access_token TEXT
+);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+CREATE TABLE recipes (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    sku TEXT,
+    number_of_cups BIGINT,
+    ingredients JSONB,
+    cogs DECIMAL
+);
+CREATE INDEX idx_recipes_deleted_at ON recipes (deleted_at);
+`
+
+// TestMigrateBaselineSchema upgrades a database created by the first release.
+// It needs a Postgres server, named by TEST_DATABASE_URL, and works in a
+// schema of its own that it drops afterwards.
+func TestMigrateBaselineSchema(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	schema := fmt.Sprintf("baseline_%d", time.Now().UnixNano())
+	admin := Connect(config.Database{Driver: "postgres", URL: dsn})
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	db := WithoutTenant(Connect(config.Database{Driver: "postgres", URL: u.String()}))
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(baselineSchema).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`
+INSERT INTO users (created_at, email) VALUES (NOW(), 'barista@example.com'), (NOW(), 'barista@example.com'), (NOW(), 'owner@example.com');
+INSERT INTO inventories (created_at, item_name, quantity, uom, price_per_qty) VALUES (NOW(), 'Beans', 1, 'kg', 200000), (NOW(), 'beans', 2, 'kg', 210000), (NOW(), 'Beans (2)', 1, 'kg', 220000);
+INSERT INTO recipes (created_at, sku, number_of_cups, ingredients, cogs) VALUES (NOW(), 'IC-20250101-001', 1, '{"Beans": {"amount": 18, "unit": "g"}}', 3600);
+`).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var users []struct {
+		Email          string
+		OrganizationID uint
+		Role           string
+	}
+	db.Table("users").Order("id").Find(&users)
+	if assert.Len(t, users, 2) {
+		assert.Equal(t, "barista@example.com", users[0].Email)
+		assert.Equal(t, "owner@example.com", users[1].Email)
+		assert.NotZero(t, users[0].OrganizationID)
+		assert.Equal(t, users[0].OrganizationID, users[1].OrganizationID)
+		assert.Equal(t, "owner", users[0].Role)
+	}
+
+	var unscoped int64
+	db.Table("inventories").Where("organization_id IS NULL").Count(&unscoped)
+	assert.Zero(t, unscoped)
+	db.Table("recipes").Where("organization_id IS NULL").Count(&unscoped)
+	assert.Zero(t, unscoped)
+
+	var names []string
+	db.Table("inventories").Order("id").Pluck("item_name", &names)
+	assert.Equal(t, []string{"Beans", "beans (2) (2)", "Beans (2)"}, names)
+
+	assert.Error(t, db.Exec("INSERT INTO users (created_at, email) VALUES (NOW(), 'owner@example.com')").Error)
+	assert.NoError(t, CheckMigrations(db))
+}
+
+// TestMigrateRenamesDuplicateItems builds the unique item name index over
+// duplicates whose plain " (<id>)" name is already taken
+func TestMigrateRenamesDuplicateItems(t *testing.T) {
+	db := WithoutTenant(Connect(config.Database{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "test.db")}))
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := Migrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Go back to the schema before 0005
+	if _, err := MigrateDown(db, len(migrations)-4); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`
+INSERT INTO organizations (id, created_at, name) VALUES (1, CURRENT_TIMESTAMP, 'Default'), (2, CURRENT_TIMESTAMP, 'Other');
+INSERT INTO inventories (id, organization_id, item_name, quantity, uom, price_per_qty) VALUES
+    (1, 1, 'Beans', 1, 'kg', 200000),
+    (2, 1, 'beans', 1, 'kg', 210000),
+    (3, 1, 'Beans (2)', 1, 'kg', 220000),
+    (4, 1, 'BEANS (2) (2)', 1, 'kg', 230000),
+    (5, 2, 'beans', 1, 'kg', 240000);
+`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	db.Table("inventories").Order("id").Pluck("item_name", &names)
+	assert.Equal(t, []string{"Beans", "beans (2) (2) (2)", "Beans (2)", "BEANS (2) (2)", "beans"}, names)
+}
+
+// TestMigrateFoldsItemNamesInEveryLetter renames items that only the Unicode
+// aware LOWER finds to be duplicates, and rebuilds the index with it
+func TestMigrateFoldsItemNamesInEveryLetter(t *testing.T) {
+	db := WithoutTenant(Connect(config.Database{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "test.db")}))
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateDown(db, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The built-in LOWER left "CRÈME" and "crème" apart, as an index on the plain name does
+	err := db.Exec(`
+DROP INDEX idx_inventories_organization_item_name;
+CREATE UNIQUE INDEX idx_inventories_organization_item_name ON inventories (organization_id, item_name) WHERE deleted_at IS NULL;
+INSERT INTO organizations (id, created_at, name) VALUES (1, CURRENT_TIMESTAMP, 'Default');
+INSERT INTO inventories (id, organization_id, item_name, quantity, uom, price_per_qty) VALUES
+    (1, 1, 'CRÈME', 1, 'liter', 60000),
+    (2, 1, 'crème', 1, 'liter', 65000);
+`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	db.Table("inventories").Order("id").Pluck("item_name", &names)
+	assert.Equal(t, []string{"CRÈME", "crème (2)"}, names)
+
+	err = db.Exec("INSERT INTO inventories (organization_id, item_name, quantity, uom, price_per_qty) VALUES (1, 'Crème', 1, 'liter', 1)").Error
+	assert.Error(t, err)
+}
@@ -0,0 +1,201 @@
+-- Databases created by the old AutoMigrate setup already have these tables,
+-- so they are only created when missing, and the columns added to them since
+-- the first release are added when missing.
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    name TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_organizations_deleted_at ON organizations (deleted_at);
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    organization_id BIGINT REFERENCES organizations (id),
+    email TEXT NOT NULL,
+    role VARCHAR(20),
+    deactivated_at TIMESTAMP WITH TIME ZONE
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS organization_id BIGINT REFERENCES organizations (id);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_users_organization_id ON users (organization_id);
+
+CREATE TABLE IF NOT EXISTS inventories (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    organization_id BIGINT REFERENCES organizations (id),
+    item_name TEXT NOT NULL,
+    quantity NUMERIC NOT NULL DEFAULT 0,
+    uom TEXT NOT NULL,
+    price_per_qty NUMERIC NOT NULL DEFAULT 0
+);
+ALTER TABLE inventories ADD COLUMN IF NOT EXISTS organization_id BIGINT REFERENCES organizations (id);
+CREATE INDEX IF NOT EXISTS idx_inventories_deleted_at ON inventories (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_inventories_organization_id ON inventories (organization_id);
+
+CREATE TABLE IF NOT EXISTS recipes (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    organization_id BIGINT REFERENCES organizations (id),
+    sku TEXT NOT NULL,
+    number_of_cups BIGINT NOT NULL DEFAULT 0,
+    ingredients JSONB,
+    cogs NUMERIC NOT NULL DEFAULT 0
+);
+ALTER TABLE recipes ADD COLUMN IF NOT EXISTS organization_id BIGINT REFERENCES organizations (id);
+CREATE INDEX IF NOT EXISTS idx_recipes_deleted_at ON recipes (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_recipes_organization_id ON recipes (organization_id);
+
+CREATE TABLE IF NOT EXISTS invitations (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    organization_id BIGINT REFERENCES organizations (id),
+    email TEXT NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    invited_by_id BIGINT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS idx_invitations_deleted_at ON invitations (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_invitations_organization_id ON invitations (organization_id);
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations (email);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    refresh_token_hash TEXT NOT NULL,
+    previous_token_hash TEXT,
+    user_agent TEXT,
+    ip TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_deleted_at ON sessions (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_refresh_token_hash ON sessions (refresh_token_hash);
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_token_hash ON sessions (previous_token_hash);
+
+CREATE TABLE IF NOT EXISTS login_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    email TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    consumed_at TIMESTAMP WITH TIME ZONE,
+    request_ip TEXT,
+    request_user_agent TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_login_tokens_deleted_at ON login_tokens (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_login_tokens_email ON login_tokens (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_tokens_token_hash ON login_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS login_codes (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    email TEXT NOT NULL,
+    code_hash TEXT NOT NULL,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    consumed_at TIMESTAMP WITH TIME ZONE,
+    request_ip TEXT,
+    request_user_agent TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_login_codes_deleted_at ON login_codes (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_login_codes_email ON login_codes (email);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    organization_id BIGINT REFERENCES organizations (id),
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    scopes JSONB NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_deleted_at ON api_keys (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_api_keys_organization_id ON api_keys (organization_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash);
+
+CREATE TABLE IF NOT EXISTS outbound_emails (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    to_address TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    html TEXT NOT NULL,
+    text TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT,
+    sent_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS idx_outbound_emails_deleted_at ON outbound_emails (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_outbound_emails_to_address ON outbound_emails (to_address);
+CREATE INDEX IF NOT EXISTS idx_outbound_emails_status ON outbound_emails (status);
+CREATE INDEX IF NOT EXISTS idx_outbound_emails_next_attempt_at ON outbound_emails (next_attempt_at);
+
+-- The first release did not keep emails unique. Keep the oldest active user of
+-- each email, or else the oldest one, and move the sessions and API keys of
+-- its duplicates onto it.
+CREATE TEMPORARY TABLE kept_users ON COMMIT DROP AS
+SELECT DISTINCT ON (email) email, id FROM users WHERE email IS NOT NULL
+ORDER BY email, deleted_at IS NOT NULL, id;
+UPDATE sessions SET user_id = kept_users.id
+FROM users, kept_users
+WHERE sessions.user_id = users.id AND users.email = kept_users.email AND users.id <> kept_users.id;
+UPDATE api_keys SET user_id = kept_users.id
+FROM users, kept_users
+WHERE api_keys.user_id = users.id AND users.email = kept_users.email AND users.id <> kept_users.id;
+DELETE FROM users WHERE email IS NOT NULL AND id NOT IN (SELECT id FROM kept_users);
+
+-- AutoMigrate created a plain index here; a user is looked up by email alone
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+
+-- Rows created before multi-tenancy were shared by every user, so they move
+-- into a single organization together
+INSERT INTO organizations (created_at, updated_at, name)
+SELECT CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Default'
+WHERE EXISTS (SELECT 1 FROM users WHERE organization_id IS NULL OR organization_id = 0)
+   OR EXISTS (SELECT 1 FROM inventories WHERE organization_id IS NULL OR organization_id = 0)
+   OR EXISTS (SELECT 1 FROM recipes WHERE organization_id IS NULL OR organization_id = 0);
+
+UPDATE users SET organization_id = (SELECT max(id) FROM organizations WHERE name = 'Default')
+WHERE organization_id IS NULL OR organization_id = 0;
+UPDATE inventories SET organization_id = (SELECT max(id) FROM organizations WHERE name = 'Default')
+WHERE organization_id IS NULL OR organization_id = 0;
+UPDATE recipes SET organization_id = (SELECT max(id) FROM organizations WHERE name = 'Default')
+WHERE organization_id IS NULL OR organization_id = 0;
+
+-- Users created before roles existed keep the full access they already had
+UPDATE users SET role = 'owner' WHERE role IS NULL OR role = '';
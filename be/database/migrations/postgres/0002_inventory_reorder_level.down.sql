@@ -0,0 +1 @@
+ALTER TABLE inventories DROP COLUMN IF EXISTS reorder_level;
@@ -0,0 +1,2 @@
+-- Items at or below their reorder level count as low on stock; 0 disables the alert
+ALTER TABLE inventories ADD COLUMN IF NOT EXISTS reorder_level NUMERIC NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- The template an email was rendered from, so deliveries can be counted per kind of email
+ALTER TABLE outbound_emails ADD COLUMN IF NOT EXISTS template VARCHAR(50) NOT NULL DEFAULT '';
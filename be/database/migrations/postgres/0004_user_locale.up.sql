@@ -0,0 +1,2 @@
+-- The language a user wants API messages in, empty to follow Accept-Language
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT '';
@@ -0,0 +1,32 @@
+-- Recipes find ingredients by item name, so an organization may have only one
+-- active item per name, ignoring case. Rename existing duplicates after the
+-- oldest one so the index can be built; merge them with POST /admin/inventory/:id/merge.
+-- A duplicate gets " (<id>)" appended until its name is free, so it cannot take
+-- the name of another item, and two renamed items never end in the same id.
+WITH RECURSIVE duplicates AS (
+    SELECT id, organization_id, item_name FROM inventories
+    WHERE deleted_at IS NULL AND EXISTS (
+        SELECT 1 FROM inventories AS older
+        WHERE older.organization_id = inventories.organization_id
+          AND LOWER(older.item_name) = LOWER(inventories.item_name)
+          AND older.deleted_at IS NULL
+          AND older.id < inventories.id
+    )
+), renames (id, organization_id, item_name) AS (
+    SELECT id, organization_id, item_name || ' (' || id || ')' FROM duplicates
+    UNION ALL
+    SELECT renames.id, renames.organization_id, renames.item_name || ' (' || renames.id || ')' FROM renames
+    WHERE EXISTS (
+        SELECT 1 FROM inventories AS taken
+        WHERE taken.organization_id = renames.organization_id
+          AND LOWER(taken.item_name) = LOWER(renames.item_name)
+          AND taken.deleted_at IS NULL
+          AND taken.id NOT IN (SELECT id FROM duplicates)
+    )
+)
+UPDATE inventories SET item_name = (
+    SELECT item_name FROM renames WHERE renames.id = inventories.id
+    ORDER BY LENGTH(item_name) DESC LIMIT 1
+)
+WHERE id IN (SELECT id FROM duplicates);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_inventories_organization_item_name ON inventories (organization_id, LOWER(item_name)) WHERE deleted_at IS NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE recipes DROP COLUMN IF EXISTS version;
+ALTER TABLE inventories DROP COLUMN IF EXISTS version;
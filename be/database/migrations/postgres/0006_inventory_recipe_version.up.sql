@@ -0,0 +1,3 @@
+-- Bumped on every change, served as the ETag that If-Match is checked against
+ALTER TABLE inventories ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE recipes ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_outbound_emails_organization_id;
+ALTER TABLE outbound_emails DROP COLUMN IF EXISTS organization_id;
@@ -0,0 +1,12 @@
+-- The organization an email was sent for, so the outbox endpoints only show an
+-- organization its own emails. Sign-up emails belong to no organization yet.
+-- Existing emails are attributed to the recipient's current organization, or
+-- else to the organization that last invited them.
+ALTER TABLE outbound_emails ADD COLUMN IF NOT EXISTS organization_id BIGINT REFERENCES organizations (id);
+UPDATE outbound_emails SET organization_id = COALESCE(
+    (SELECT MIN(users.organization_id) FROM users WHERE users.email = outbound_emails.to_address AND users.deleted_at IS NULL),
+    (SELECT invitations.organization_id FROM invitations WHERE invitations.email = outbound_emails.to_address AND invitations.deleted_at IS NULL
+     ORDER BY invitations.created_at DESC LIMIT 1)
+)
+WHERE organization_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbound_emails_organization_id ON outbound_emails (organization_id);
@@ -0,0 +1,2 @@
+-- Postgres' LOWER already folds every letter, so only SQLite needs this migration.
+SELECT 1;
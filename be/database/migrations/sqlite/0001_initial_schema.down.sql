@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS outbound_emails;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS login_codes;
+DROP TABLE IF EXISTS login_tokens;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS invitations;
+DROP TABLE IF EXISTS recipes;
+DROP TABLE IF EXISTS inventories;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,157 @@
+CREATE TABLE organizations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    name TEXT NOT NULL
+);
+CREATE INDEX idx_organizations_deleted_at ON organizations (deleted_at);
+
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    organization_id INTEGER REFERENCES organizations (id),
+    email TEXT NOT NULL,
+    role VARCHAR(20),
+    deactivated_at DATETIME
+);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+CREATE INDEX idx_users_organization_id ON users (organization_id);
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+
+CREATE TABLE inventories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    organization_id INTEGER REFERENCES organizations (id),
+    item_name TEXT NOT NULL,
+    quantity REAL NOT NULL DEFAULT 0,
+    uom TEXT NOT NULL,
+    price_per_qty REAL NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_inventories_deleted_at ON inventories (deleted_at);
+CREATE INDEX idx_inventories_organization_id ON inventories (organization_id);
+
+CREATE TABLE recipes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    organization_id INTEGER REFERENCES organizations (id),
+    sku TEXT NOT NULL,
+    number_of_cups INTEGER NOT NULL DEFAULT 0,
+    ingredients JSON,
+    cogs REAL NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_recipes_deleted_at ON recipes (deleted_at);
+CREATE INDEX idx_recipes_organization_id ON recipes (organization_id);
+
+CREATE TABLE invitations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    organization_id INTEGER REFERENCES organizations (id),
+    email TEXT NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    invited_by_id INTEGER,
+    expires_at DATETIME NOT NULL,
+    accepted_at DATETIME
+);
+CREATE INDEX idx_invitations_deleted_at ON invitations (deleted_at);
+CREATE INDEX idx_invitations_organization_id ON invitations (organization_id);
+CREATE INDEX idx_invitations_email ON invitations (email);
+
+CREATE TABLE sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    refresh_token_hash TEXT NOT NULL,
+    previous_token_hash TEXT,
+    user_agent TEXT,
+    ip TEXT,
+    expires_at DATETIME NOT NULL,
+    last_used_at DATETIME NOT NULL,
+    revoked_at DATETIME
+);
+CREATE INDEX idx_sessions_deleted_at ON sessions (deleted_at);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+CREATE UNIQUE INDEX idx_sessions_refresh_token_hash ON sessions (refresh_token_hash);
+CREATE INDEX idx_sessions_previous_token_hash ON sessions (previous_token_hash);
+
+CREATE TABLE login_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    email TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    consumed_at DATETIME,
+    request_ip TEXT,
+    request_user_agent TEXT
+);
+CREATE INDEX idx_login_tokens_deleted_at ON login_tokens (deleted_at);
+CREATE INDEX idx_login_tokens_email ON login_tokens (email);
+CREATE UNIQUE INDEX idx_login_tokens_token_hash ON login_tokens (token_hash);
+
+CREATE TABLE login_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    email TEXT NOT NULL,
+    code_hash TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    consumed_at DATETIME,
+    request_ip TEXT,
+    request_user_agent TEXT
+);
+CREATE INDEX idx_login_codes_deleted_at ON login_codes (deleted_at);
+CREATE INDEX idx_login_codes_email ON login_codes (email);
+
+CREATE TABLE api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    organization_id INTEGER REFERENCES organizations (id),
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    scopes JSON NOT NULL,
+    expires_at DATETIME,
+    last_used_at DATETIME,
+    revoked_at DATETIME
+);
+CREATE INDEX idx_api_keys_deleted_at ON api_keys (deleted_at);
+CREATE INDEX idx_api_keys_organization_id ON api_keys (organization_id);
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
+CREATE UNIQUE INDEX idx_api_keys_key_hash ON api_keys (key_hash);
+
+CREATE TABLE outbound_emails (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    to_address TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    html TEXT NOT NULL,
+    text TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error TEXT,
+    sent_at DATETIME
+);
+CREATE INDEX idx_outbound_emails_deleted_at ON outbound_emails (deleted_at);
+CREATE INDEX idx_outbound_emails_to_address ON outbound_emails (to_address);
+CREATE INDEX idx_outbound_emails_status ON outbound_emails (status);
+CREATE INDEX idx_outbound_emails_next_attempt_at ON outbound_emails (next_attempt_at);
@@ -0,0 +1 @@
+ALTER TABLE outbound_emails DROP COLUMN template;
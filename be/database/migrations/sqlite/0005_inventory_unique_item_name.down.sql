@@ -0,0 +1 @@
+DROP INDEX idx_inventories_organization_item_name;
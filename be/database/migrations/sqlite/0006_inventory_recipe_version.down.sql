@@ -0,0 +1,2 @@
+ALTER TABLE recipes DROP COLUMN version;
+ALTER TABLE inventories DROP COLUMN version;
@@ -0,0 +1,3 @@
+-- Bumped on every change, served as the ETag that If-Match is checked against
+ALTER TABLE inventories ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE recipes ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
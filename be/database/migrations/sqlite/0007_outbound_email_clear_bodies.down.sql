@@ -0,0 +1,2 @@
+-- The cleared bodies cannot be restored
+SELECT 1;
@@ -0,0 +1,4 @@
+-- Sent emails and dead sign-in emails no longer keep their body, which may
+-- hold a working sign-in link and code
+UPDATE outbound_emails SET html = '', text = ''
+WHERE status = 'sent' OR (status = 'dead' AND template = 'login');
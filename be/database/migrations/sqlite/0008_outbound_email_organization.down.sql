@@ -0,0 +1,2 @@
+DROP INDEX idx_outbound_emails_organization_id;
+ALTER TABLE outbound_emails DROP COLUMN organization_id;
@@ -0,0 +1,3 @@
+-- The renamed duplicates keep their names. The index is rebuilt in case the
+-- database is opened with the built-in LOWER again.
+REINDEX idx_inventories_organization_item_name;
@@ -0,0 +1,32 @@
+-- LOWER now folds every letter, not only ASCII ones, so items whose names differ
+-- only in the case of other letters, such as "Crème" and "CRÈME", became
+-- duplicates. The unique index was folded by the old LOWER, so it is dropped
+-- before renaming them like 0005 did, and built again after.
+DROP INDEX idx_inventories_organization_item_name;
+WITH RECURSIVE duplicates AS (
+    SELECT id, organization_id, item_name FROM inventories
+    WHERE deleted_at IS NULL AND EXISTS (
+        SELECT 1 FROM inventories AS older
+        WHERE older.organization_id = inventories.organization_id
+          AND LOWER(older.item_name) = LOWER(inventories.item_name)
+          AND older.deleted_at IS NULL
+          AND older.id < inventories.id
+    )
+), renames (id, organization_id, item_name) AS (
+    SELECT id, organization_id, item_name || ' (' || id || ')' FROM duplicates
+    UNION ALL
+    SELECT renames.id, renames.organization_id, renames.item_name || ' (' || renames.id || ')' FROM renames
+    WHERE EXISTS (
+        SELECT 1 FROM inventories AS taken
+        WHERE taken.organization_id = renames.organization_id
+          AND LOWER(taken.item_name) = LOWER(renames.item_name)
+          AND taken.deleted_at IS NULL
+          AND taken.id NOT IN (SELECT id FROM duplicates)
+    )
+)
+UPDATE inventories SET item_name = (
+    SELECT item_name FROM renames WHERE renames.id = inventories.id
+    ORDER BY LENGTH(item_name) DESC LIMIT 1
+)
+WHERE id IN (SELECT id FROM duplicates);
+CREATE UNIQUE INDEX idx_inventories_organization_item_name ON inventories (organization_id, LOWER(item_name)) WHERE deleted_at IS NULL;
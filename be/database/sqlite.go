@@ -0,0 +1,29 @@
+package database
+
+import (
+	"database/sql/driver"
+	"strings"
+
+	sqlite "github.com/glebarez/go-sqlite"
+)
+
+// SQLite's built-in LOWER only folds ASCII letters, while the repositories fold
+// names and search terms with strings.ToLower, as Postgres does. Replacing it
+// on every connection keeps the two sides of a comparison, and the unique
+// index on item names, folding case the same way.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("lower", 1, lower)
+}
+
+// lower is LOWER for SQLite connections. Values other than text are returned
+// unchanged, as they have no case to fold.
+func lower(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	switch value := args[0].(type) {
+	case string:
+		return strings.ToLower(value), nil
+	case []byte:
+		return strings.ToLower(string(value)), nil
+	default:
+		return value, nil
+	}
+}
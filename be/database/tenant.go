@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// tenantField is the model field that marks a table as owned by an organization
+const tenantField = "OrganizationID"
+
+const skipTenantKey = "tenant:skip"
+
+type tenantContextKey struct{}
+
+// ErrMissingTenant is returned when a tenant scoped model is queried without a tenant in the context
+var ErrMissingTenant = errors.New("tenant is required for this query")
+
+// WithTenant returns a copy of ctx that carries the caller's organization ID
+func WithTenant(ctx context.Context, organizationID uint) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, organizationID)
+}
+
+// TenantFromContext returns the organization ID stored by WithTenant
+func TenantFromContext(ctx context.Context) (uint, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	organizationID, ok := ctx.Value(tenantContextKey{}).(uint)
+	return organizationID, ok && organizationID != 0
+}
+
+// WithoutTenant disables tenant scoping for system tasks such as login and
+// migrations. Request handlers must not use it for tenant data.
+func WithoutTenant(db *gorm.DB) *gorm.DB {
+	return db.Set(skipTenantKey, true).Session(&gorm.Session{})
+}
+
+// registerTenantCallbacks scopes every query on a model that has an
+// OrganizationID field to the tenant carried by the statement context.
+// A query without a tenant fails instead of reading every organization.
+func registerTenantCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("tenant:create", assignTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register("tenant:query", scopeTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("tenant:row", scopeTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("tenant:update", scopeTenant); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register("tenant:delete", scopeTenant)
+}
+
+func tenantFor(db *gorm.DB) (*schema.Field, uint, bool) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil, 0, false
+	}
+	field := db.Statement.Schema.LookUpField(tenantField)
+	if field == nil {
+		return nil, 0, false
+	}
+	if skip, ok := db.Get(skipTenantKey); ok && skip == true {
+		return nil, 0, false
+	}
+
+	organizationID, ok := TenantFromContext(db.Statement.Context)
+	if !ok {
+		db.AddError(ErrMissingTenant)
+		return nil, 0, false
+	}
+	return field, organizationID, true
+}
+
+func scopeTenant(db *gorm.DB) {
+	field, organizationID, ok := tenantFor(db)
+	if !ok {
+		return
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: organizationID},
+	}})
+}
+
+func assignTenant(db *gorm.DB) {
+	field, organizationID, ok := tenantFor(db)
+	if !ok {
+		return
+	}
+
+	// Always overwrite the value so a client cannot create rows in another tenant
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := field.Set(db.Statement.Context, reflect.Indirect(rv.Index(i)), organizationID); err != nil {
+				db.AddError(err)
+				return
+			}
+		}
+	case reflect.Struct:
+		if err := field.Set(db.Statement.Context, rv, organizationID); err != nil {
+			db.AddError(err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"be-test/apperror"
+	"be-test/helpers"
+	"be-test/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+// apiKeyPrefix marks API keys so they are recognisable in logs and secret scanners
+const apiKeyPrefix = "icr_"
+
+type apiKeyInput struct {
+	Name      string              `json:"name" binding:"required"`
+	Scopes    []models.Permission `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time          `json:"expires_at"`
+}
+
+// CreateAPIKey issues an API key owned by the caller. The key is only shown in this response.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input apiKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	// A key can never do more than the user, or the key, that creates it
+	role := c.MustGet("role").(models.Role)
+	callerKey, withKey := c.Get("api_key")
+	for _, scope := range input.Scopes {
+		if !role.Can(scope) || (withKey && !callerKey.(models.APIKey).Allows(scope)) {
+			c.Error(apperror.ScopeNotAllowed.WithFields(map[string]string{"scopes": string(scope)}))
+			return
+		}
+	}
+
+	token, err := helpers.GenerateOpaqueToken()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	key := apiKeyPrefix + token
+
+	apiKey := models.APIKey{
+		UserID:    c.GetUint("user_id"),
+		Name:      input.Name,
+		Prefix:    key[:len(apiKeyPrefix)+6],
+		KeyHash:   helpers.HashToken(key),
+		Scopes:    datatypes.NewJSONSlice(input.Scopes),
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err := db.Create(&apiKey).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, gin.H{
+		"api_key": apiKey,
+		"key":     key,
+	}, nil, "", 0, "api_key.created")
+}
+
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var apiKeys []models.APIKey
+	if err := db.Order("id desc").Find(&apiKeys).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, gin.H{"api_keys": apiKeys}, nil, "", 0, "api_key.retrieved")
+}
+
+// RevokeAPIKey permanently disables an API key
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var apiKey models.APIKey
+	if err := db.First(&apiKey, pathID(c)).Error; err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.APIKeyNotFound))
+		return
+	}
+
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		if err := db.Model(&apiKey).Update("revoked_at", &now).Error; err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	helpers.NewAPIResponse(c, nil, nil, "", 0, "api_key.revoked")
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyEndpoints(t *testing.T) {
+	r := setupTestRouter()
+
+	ownerToken := loginAs(t, r, "pos-owner@example.com")
+	var key string
+	var keyID float64
+
+	t.Run("Create API Key", func(t *testing.T) {
+		jsonData, _ := json.Marshal(map[string]interface{}{
+			"name":   "POS tablet",
+			"scopes": []string{"inventory:read"},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		data := response["data"].(map[string]interface{})
+		key = data["key"].(string)
+		keyID = data["api_key"].(map[string]interface{})["ID"].(float64)
+	})
+
+	t.Run("Create API Key With Unknown Scope", func(t *testing.T) {
+		jsonData, _ := json.Marshal(map[string]interface{}{
+			"name":   "Bookkeeping",
+			"scopes": []string{"everything"},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 400, w.Code)
+	})
+
+	t.Run("Use API Key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/inventory", nil)
+		req.Header.Set("X-API-Key", key)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+
+		// The key was not granted inventory:write
+		jsonData, _ := json.Marshal(map[string]interface{}{"item_name": "Milk", "quantity": 1, "uom": "liter", "price_per_qty": 20000})
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/inventory", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 403, w.Code)
+	})
+
+	t.Run("Log Out With API Key", func(t *testing.T) {
+		for _, path := range []string{"/auth/logout", "/auth/logout-all"} {
+			w := serve(r, "POST", path, nil, "X-API-Key", key)
+			assert.Equal(t, 403, w.Code, path)
+			assert.Equal(t, "SESSION_REQUIRED", errorCode(w), path)
+		}
+
+		// Neither the key nor its creator's session was revoked
+		assert.Equal(t, 200, serve(r, "GET", "/inventory", nil, "X-API-Key", key).Code)
+		assert.Equal(t, 200, serve(r, "GET", "/inventory", nil, "Authorization", ownerToken).Code)
+	})
+
+	t.Run("Revoke API Key With A Non-Numeric ID", func(t *testing.T) {
+		for _, id := range []string{"abc", "0%20OR%20name%20=%20'POS%20tablet'"} {
+			w := serve(r, "DELETE", "/api-keys/"+id, nil, "Authorization", ownerToken)
+			assert.Equal(t, 404, w.Code, id)
+			assert.Equal(t, "API_KEY_NOT_FOUND", errorCode(w), id)
+		}
+
+		// The path is never read as SQL, so the key still works
+		assert.Equal(t, 200, serve(r, "GET", "/inventory", nil, "X-API-Key", key).Code)
+	})
+
+	t.Run("Revoke API Key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api-keys/%d", int(keyID)), nil)
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/inventory", nil)
+		req.Header.Set("X-API-Key", key)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 401, w.Code)
+	})
+}
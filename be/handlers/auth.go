@@ -1,91 +1,249 @@
 package handler
 
 import (
+	"be-test/apperror"
 	"be-test/database"
 	"be-test/helpers"
 	"be-test/models"
 	"be-test/utils"
-	"net/http"
-	"os"
+	"context"
+	"errors"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+type verifyCodeInput struct {
+	Email string `json:"email" binding:"required,email"`
+	Code  string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// loginEmail is the data of the login email template
+type loginEmail struct {
+	Email string
+	Link  string
+	Code  string
+}
+
 // SubmitEmail generates a magic link for email authentication
-func SubmitEmail(c *gin.Context) {
+func (h *Handler) SubmitEmail(c *gin.Context) {
 	var user models.User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		helpers.NewAPIResponse(c, nil, err, "binding", 0, "Invalid email format")
+		c.Error(apperror.FromBinding(err))
 		return
 	}
 
-	token, err := helpers.GenerateJWT(user.Email, time.Now().Add(time.Minute*5).Unix())
+	db := database.WithoutTenant(h.DB.WithContext(c.Request.Context()))
+
+	limited, err := loginRateLimited(db, user.Email)
 	if err != nil {
-		helpers.NewAPIResponse(c, nil, err, "token", 0, "Failed to generate token")
+		c.Error(err)
+		return
+	}
+	if limited {
+		c.Error(apperror.LoginRateLimited)
 		return
 	}
 
-	magicLink := os.Getenv("FRONTEND_URL") + "?token=" + token
-	err = utils.SendEmail(user.Email, magicLink)
-	if err != nil {
-		helpers.NewAPIResponse(c, nil, err, "send_email", 0, "")
+	// In invite-only mode unknown emails get the same answer without a link,
+	// so the endpoint does not reveal who has an account
+	if h.Config.InviteOnly {
+		allowed, err := h.canSignIn(c.Request.Context(), db, user.Email)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if !allowed {
+			helpers.NewAPIResponse(c, nil, nil, "", 0, "auth.magic_link_sent")
+			return
+		}
+	}
 
+	organizationID, err := h.organizationOf(c.Request.Context(), db, user.Email)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := h.sendMagicLink(c, db, organizationID, user.Email); err != nil {
+		c.Error(err)
 		return
 	}
 
-	helpers.NewAPIResponse(c, nil, nil, "", 0, "Magic link sent")
+	helpers.NewAPIResponse(c, nil, nil, "", 0, "auth.magic_link_sent")
+}
+
+// sendMagicLink emails a short-lived, single-use sign-in link to email, together
+// with a one-time code for signing in on another device. The email is listed
+// in the outbox of organizationID.
+func (h *Handler) sendMagicLink(c *gin.Context, db *gorm.DB, organizationID uint, email string) error {
+	locale := utils.MatchLocale(c.GetHeader("Accept-Language"))
+
+	// The email is queued with its tokens so a slow SMTP server cannot hold up sign-in
+	return db.Transaction(func(tx *gorm.DB) error {
+		token, err := newLoginToken(tx, email, c.ClientIP(), c.Request.UserAgent())
+		if err != nil {
+			return err
+		}
+
+		code, err := newLoginCode(tx, email, c.ClientIP(), c.Request.UserAgent())
+		if err != nil {
+			return err
+		}
+
+		return utils.EnqueueTemplate(tx, organizationID, locale, "login", email, loginEmail{
+			Email: email,
+			Link:  h.Config.FrontendURL + "?token=" + token,
+			Code:  code,
+		})
+	})
+}
+
+// canSignIn reports whether email belongs to an active user or has a pending invitation
+func (h *Handler) canSignIn(ctx context.Context, db *gorm.DB, email string) (bool, error) {
+	user, err := h.Users.FindByEmail(ctx, email)
+	if err == nil {
+		return user.DeactivatedAt == nil, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	_, err = findPendingInvitation(db, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// organizationOf returns the organization of the user with email or, for an
+// invited email, of its pending invitation, and 0 when there is neither
+func (h *Handler) organizationOf(ctx context.Context, db *gorm.DB, email string) (uint, error) {
+	user, err := h.Users.FindByEmail(ctx, email)
+	if err == nil {
+		return user.OrganizationID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	invitation, err := findPendingInvitation(db, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return invitation.OrganizationID, err
+}
+
+// findPendingInvitation returns the latest invitation for email that can still be accepted
+func findPendingInvitation(db *gorm.DB, email string) (models.Invitation, error) {
+	var invitation models.Invitation
+	err := db.Where("email = ? AND accepted_at IS NULL AND expires_at > ?", email, time.Now()).
+		Order("created_at desc").First(&invitation).Error
+	return invitation, err
 }
 
 // MagicLink authenticates a user via the magic link
-func MagicLink(c *gin.Context) {
+func (h *Handler) MagicLink(c *gin.Context) {
 	token := c.DefaultQuery("token", "")
 	if token == "" {
-		helpers.NewAPIResponse(c, nil, nil, "token", http.StatusBadRequest, "Token is required")
+		c.Error(apperror.LoginTokenRequired)
 		return
 	}
 
-	// Validate JWT token
-	claims, err := helpers.ValidateJWT(token)
+	db := database.WithoutTenant(h.DB.WithContext(c.Request.Context()))
+
+	// Consume the token so the link cannot be used twice
+	email, err := consumeLoginToken(db, token)
 	if err != nil {
-		helpers.NewAPIResponse(c, nil, nil, "token", http.StatusUnauthorized, "Invalid or expired token")
+		c.Error(err)
 		return
 	}
 
-	email := claims["email"].(string)
+	h.signIn(c, db, email)
+}
 
-	// Check if token already exists in database
-	var existingToken models.User
-	result := database.DB.Where("access_token = ?", token).First(&existingToken)
-	if result.Error == nil {
-		helpers.NewAPIResponse(c, nil, nil, "token", http.StatusUnauthorized, "Token already used")
+// VerifyCode authenticates a user via the one-time code from the sign-in email
+func (h *Handler) VerifyCode(c *gin.Context) {
+	var input verifyCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
 		return
 	}
 
-	// Generate new access token
-	newToken, err := helpers.GenerateJWT(email, time.Now().Add(time.Hour*24).Unix())
-	if err != nil {
-		helpers.NewAPIResponse(c, nil, nil, "newToken", 0, "Failed to generate token")
+	db := database.WithoutTenant(h.DB.WithContext(c.Request.Context()))
+
+	if err := consumeLoginCode(db, input.Email, input.Code); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Check if user exists and update token, or create new user
-	var user models.User
-	result = database.DB.Where("email = ?", email).First(&user)
-	if result.Error == nil {
-		// User exists, update token
-		database.DB.Model(&user).Update("access_token", token)
+	h.signIn(c, db, input.Email)
+}
+
+// signIn finds or creates the user for a verified email and responds with a new session
+func (h *Handler) signIn(c *gin.Context, db *gorm.DB, email string) {
+	// Check if user exists, or create new user
+	user, err := h.Users.FindByEmail(c.Request.Context(), email)
+	if err == nil {
+		if user.DeactivatedAt != nil {
+			c.Error(apperror.UserDeactivated)
+			return
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(err)
+		return
 	} else {
-		// Create new user
-		user = models.User{
-			Email:       email,
-			AccessToken: token,
+		invitation, err := findPendingInvitation(db, email)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(err)
+			return
+		}
+		invited := err == nil
+		if !invited && h.Config.InviteOnly {
+			c.Error(apperror.InvitationRequired)
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if invited {
+				// Join the inviting organization with the invited role
+				now := time.Now()
+				if err := tx.Model(&invitation).Update("accepted_at", &now).Error; err != nil {
+					return err
+				}
+				user = models.User{
+					OrganizationID: invitation.OrganizationID,
+					Email:          email,
+					Role:           invitation.Role,
+				}
+				return tx.Create(&user).Error
+			}
+
+			// Create new user as the owner of an organization of their own
+			organization := models.Organization{Name: email}
+			if err := tx.Create(&organization).Error; err != nil {
+				return err
+			}
+
+			user = models.User{
+				OrganizationID: organization.ID,
+				Email:          email,
+				Role:           models.RoleOwner,
+			}
+			return tx.Create(&user).Error
+		})
+		if err != nil {
+			c.Error(err)
+			return
 		}
-		database.DB.Create(&user)
 	}
 
-	helpers.NewAPIResponse(c, gin.H{
-		"access_token": newToken,
-	}, nil, "authenticated", 0, "Authentication successful")
+	// Open a session and hand out short-lived access and rotating refresh tokens
+	tokens, err := issueSession(c, db, user)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
+	helpers.NewAPIResponse(c, tokens, nil, "authenticated", 0, "auth.authenticated")
 }
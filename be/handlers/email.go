@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"be-test/apperror"
+	"be-test/helpers"
+	"be-test/models"
+	"be-test/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// organizationEmails limits the outbox to the emails sent for the caller's organization
+func (h *Handler) organizationEmails(c *gin.Context) *gorm.DB {
+	return h.DB.WithContext(c.Request.Context()).Model(&models.OutboundEmail{})
+}
+
+func (h *Handler) GetOutboundEmails(c *gin.Context) {
+	page, limit, opts := pagination(c)
+	status := c.DefaultQuery("status", "")
+
+	var emails []models.OutboundEmail
+	var totalItems int64
+
+	query := h.organizationEmails(c)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&totalItems).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if err := query.Offset(opts.Offset).Limit(opts.Limit).Order("id desc").Find(&emails).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, gin.H{
+		"page":        page,
+		"limit":       limit,
+		"total_items": totalItems,
+		"total_pages": (totalItems + int64(limit) - 1) / int64(limit),
+		"emails":      emails,
+	}, nil, "", 0, "email.retrieved")
+}
+
+// RetryOutboundEmail puts a failed or dead email back in the queue with a fresh
+// attempt budget. Sign-in emails cannot be retried; the user requests a new one.
+func (h *Handler) RetryOutboundEmail(c *gin.Context) {
+	var email models.OutboundEmail
+	if err := h.organizationEmails(c).First(&email, pathID(c)).Error; err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.EmailNotFound))
+		return
+	}
+
+	if email.Status == models.EmailSent {
+		c.Error(apperror.EmailAlreadySent)
+		return
+	}
+	// The sign-in link and code it carried expired long ago
+	if !utils.Retryable(email.Template) {
+		c.Error(apperror.EmailNotRetryable)
+		return
+	}
+
+	err := h.organizationEmails(c).Where("id = ?", email.ID).Updates(map[string]interface{}{
+		"status":          models.EmailPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	}).Error
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, gin.H{"email": email}, nil, "", 0, "email.retry_queued")
+}
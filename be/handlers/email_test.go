@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"be-test/database"
+	"be-test/models"
+	"be-test/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingMailer simulates an SMTP server that rejects every message
+type failingMailer struct{}
+
+func (failingMailer) Send(ctx context.Context, msg utils.Message) error {
+	return errors.New("connection refused")
+}
+
+func TestEmailOutboxEndpoints(t *testing.T) {
+	r := setupTestRouter()
+
+	ownerToken := loginAs(t, r, "outbox-owner@example.com")
+	var emailID float64
+
+	t.Run("Failed Email Becomes Dead", func(t *testing.T) {
+		jsonData, _ := json.Marshal(map[string]string{"email": "unreachable@example.com", "role": "viewer"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/users/invitations", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+
+		worker := utils.NewEmailWorker(testDB, failingMailer{})
+		worker.MaxAttempts = 1
+		if err := worker.ProcessDue(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/admin/emails?status=dead", nil)
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		data := response["data"].(map[string]interface{})
+		emails := data["emails"].([]interface{})
+		assert.Len(t, emails, 1)
+
+		email := emails[0].(map[string]interface{})
+		assert.Equal(t, "unreachable@example.com", email["to_address"])
+		assert.Equal(t, "connection refused", email["last_error"])
+		emailID = email["ID"].(float64)
+	})
+
+	t.Run("Retry Sign-in Email", func(t *testing.T) {
+		var email models.OutboundEmail
+		if err := database.WithoutTenant(testDB).First(&email, int(emailID)).Error; err != nil {
+			t.Fatal(err)
+		}
+		assert.Empty(t, email.HTML)
+		assert.Empty(t, email.Text)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/admin/emails/%d/retry", int(emailID)), nil)
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 409, w.Code)
+		assert.Equal(t, "EMAIL_NOT_RETRYABLE", errorCode(w))
+	})
+
+	t.Run("Retry Email", func(t *testing.T) {
+		var owner models.User
+		if err := database.WithoutTenant(testDB).Where("email = ?", "outbox-owner@example.com").First(&owner).Error; err != nil {
+			t.Fatal(err)
+		}
+		dead := models.OutboundEmail{
+			OrganizationID: &owner.OrganizationID,
+			Template:       "digest",
+			ToAddress:      "unreachable@example.com",
+			Subject:        "Weekly digest",
+			Text:           "Stock is healthy",
+			Status:         models.EmailDead,
+			Attempts:       1,
+			NextAttemptAt:  time.Now(),
+		}
+		if err := database.WithoutTenant(testDB).Create(&dead).Error; err != nil {
+			t.Fatal(err)
+		}
+		emailID = float64(dead.ID)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/admin/emails/%d/retry", dead.ID), nil)
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+
+		deliverEmails(t)
+		messages := testOutbox.Messages()
+		assert.Equal(t, "unreachable@example.com", messages[len(messages)-1].To)
+		assert.Equal(t, "Stock is healthy", messages[len(messages)-1].Text)
+
+		// Sent emails do not keep their body
+		if err := database.WithoutTenant(testDB).First(&dead, dead.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, models.EmailSent, dead.Status)
+		assert.Empty(t, dead.Text)
+	})
+
+	t.Run("Retry Email With A Non-Numeric ID", func(t *testing.T) {
+		for _, id := range []string{"abc", "0%20OR%201=1"} {
+			w := serve(r, "POST", "/admin/emails/"+id+"/retry", nil, "Authorization", ownerToken)
+			assert.Equal(t, 404, w.Code, id)
+			assert.Equal(t, "EMAIL_NOT_FOUND", errorCode(w), id)
+		}
+	})
+
+	t.Run("Get Emails When The Query Fails", func(t *testing.T) {
+		// fakeAuth puts no tenant on the request context, so every scoped query fails
+		r := newTestRouter(New(testConfig(), testDB, testOutbox), fakeAuth)
+		w := serve(r, "GET", "/admin/emails", nil)
+		assert.Equal(t, 500, w.Code)
+		assert.Equal(t, "INTERNAL", errorCode(w))
+	})
+
+	t.Run("Get Emails Of Another Organization", func(t *testing.T) {
+		otherToken := loginAs(t, r, "outbox-other@example.com")
+		emailsTo := func(token string, address string) int {
+			w := serve(r, "GET", "/admin/emails?limit=100", nil, "Authorization", token)
+			var response struct {
+				Data struct {
+					Emails []models.OutboundEmail `json:"emails"`
+				} `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			count := 0
+			for _, email := range response.Data.Emails {
+				if email.ToAddress == address {
+					count++
+				}
+			}
+			return count
+		}
+
+		w := serve(r, "POST", fmt.Sprintf("/admin/emails/%d/retry", int(emailID)), nil, "Authorization", otherToken)
+		assert.Equal(t, 404, w.Code)
+
+		// An email invited by one organization that joins another later
+		w = serve(r, "POST", "/users/invitations", `{"email": "switcher@example.com", "role": "viewer"}`, "Authorization", ownerToken)
+		assert.Equal(t, 200, w.Code)
+		database.WithoutTenant(testDB).Model(&models.Invitation{}).Where("email = ?", "switcher@example.com").
+			Update("expires_at", time.Now().Add(-time.Minute))
+		w = serve(r, "POST", "/users/invitations", `{"email": "switcher@example.com", "role": "viewer"}`, "Authorization", otherToken)
+		assert.Equal(t, 200, w.Code)
+		loginAs(t, r, "switcher@example.com")
+		w = serve(r, "POST", "/auth/submit-email", `{"email": "switcher@example.com"}`)
+		assert.Equal(t, 200, w.Code)
+
+		assert.Equal(t, 1, emailsTo(ownerToken, "switcher@example.com"))
+		assert.Equal(t, 2, emailsTo(otherToken, "switcher@example.com"))
+	})
+}
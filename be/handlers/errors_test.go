@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"be-test/models"
+	"be-test/repository"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// errorCode returns the error code of an API response
+func errorCode(w *httptest.ResponseRecorder) string {
+	var response struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Error.Code
+}
+
+// brokenInventory fails every listing the way a broken database would
+type brokenInventory struct {
+	*fakeInventory
+}
+
+func (brokenInventory) List(ctx context.Context, opts repository.ListOptions) ([]models.Inventory, int64, error) {
+	return nil, 0, errors.New(`pq: relation "secret_inventory" does not exist`)
+}
+
+func TestErrorResponses(t *testing.T) {
+	r := setupTestRouter()
+	token := loginAs(t, r, "errors@example.com")
+
+	t.Run("Missing Token", func(t *testing.T) {
+		w := serve(r, "GET", "/inventory", nil)
+		assert.Equal(t, 401, w.Code)
+		assert.Equal(t, "TOKEN_REQUIRED", errorCode(w))
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		w := serve(r, "PUT", "/inventory/9999", `{"quantity": 1}`, "Authorization", token)
+		assert.Equal(t, 404, w.Code)
+		assert.Equal(t, "INVENTORY_NOT_FOUND", errorCode(w))
+		assert.NotContains(t, w.Body.String(), "record not found")
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		w := serve(r, "POST", "/inventory", `{"item_name": `, "Authorization", token)
+		assert.Equal(t, 400, w.Code)
+		assert.Equal(t, "INVALID_JSON", errorCode(w))
+	})
+
+	t.Run("Validation Fields", func(t *testing.T) {
+		w := serve(r, "POST", "/auth/verify-code", `{"email": "errors@example.com", "code": "12ab"}`)
+		assert.Equal(t, 400, w.Code)
+		assert.Equal(t, "VALIDATION_FAILED", errorCode(w))
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		fields := response["error"].(map[string]interface{})["fields"].(map[string]interface{})
+		assert.Contains(t, fields, "code")
+	})
+
+	t.Run("Unit Incompatible", func(t *testing.T) {
+		w := serve(r, "POST", "/inventory", `{"item_name": "Errors Syrup", "quantity": 1, "uom": "Liter", "price_per_qty": 50000}`, "Authorization", token)
+		assert.Equal(t, 200, w.Code)
+
+		w = serve(r, "POST", "/recipe", `{"number_of_cups": 1, "ingredients": {"Errors Syrup": {"amount": 1, "unit": "pcs"}}}`, "Authorization", token)
+		assert.Equal(t, 422, w.Code)
+		assert.Equal(t, "UNIT_INCOMPATIBLE", errorCode(w))
+		assert.Contains(t, w.Body.String(), "ingredients[Errors Syrup].unit")
+	})
+
+	t.Run("Internal Errors Hide Their Cause", func(t *testing.T) {
+		h := &Handler{Inventory: brokenInventory{newFakeInventory()}, Recipes: newFakeRecipes()}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/inventory", bytes.NewReader(nil))
+		newTestRouter(h, fakeAuth).ServeHTTP(w, req)
+
+		assert.Equal(t, 500, w.Code)
+		assert.Equal(t, "INTERNAL", errorCode(w))
+		assert.NotContains(t, w.Body.String(), "secret_inventory")
+	})
+}
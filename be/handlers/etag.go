@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"be-test/apperror"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag returns the ETag of a resource at version
+func etag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// etagMatches reports whether header, an If-Match or If-None-Match list of
+// ETags, names version or is *
+func etagMatches(header string, version uint) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified answers 304 and returns true when the If-None-Match header
+// names version, so the client's copy is current
+func notModified(c *gin.Context, version uint) bool {
+	c.Header("ETag", etag(version))
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, version) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// checkIfMatch requires the If-Match header to name version, the resource's
+// current version. Otherwise it answers 428 when the header is missing, or 412
+// with current, the resource as it is now, and returns false.
+func checkIfMatch(c *gin.Context, version uint, current gin.H) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.Error(apperror.PreconditionRequired)
+		return false
+	}
+	if !etagMatches(header, version) {
+		preconditionFailed(c, version, current)
+		return false
+	}
+	return true
+}
+
+// preconditionFailed answers 412 with current, the resource as it is now at version
+func preconditionFailed(c *gin.Context, version uint, current gin.H) {
+	c.Header("ETag", etag(version))
+	c.Error(apperror.PreconditionFailed.WithData(current))
+}
@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"be-test/models"
+	"be-test/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeInventory is an in-memory InventoryRepository for a single organization
+type fakeInventory struct {
+	items  map[uint]models.Inventory
+	nextID uint
+	// recipes receives the recipes rewritten by Merge
+	recipes *fakeRecipes
+}
+
+func newFakeInventory() *fakeInventory {
+	return &fakeInventory{items: map[uint]models.Inventory{}, nextID: 1}
+}
+
+func (f *fakeInventory) List(ctx context.Context, opts repository.ListOptions) ([]models.Inventory, int64, error) {
+	var matches []models.Inventory
+	for _, item := range f.items {
+		if strings.Contains(strings.ToLower(item.ItemName), strings.ToLower(opts.Search)) {
+			matches = append(matches, item)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+	return page(matches, opts), int64(len(matches)), nil
+}
+
+func (f *fakeInventory) Get(ctx context.Context, id uint) (models.Inventory, error) {
+	item, ok := f.items[id]
+	if !ok {
+		return item, repository.ErrNotFound
+	}
+	return item, nil
+}
+
+// nameTaken reports whether another item has item's name, ignoring case, like the unique index
+func (f *fakeInventory) nameTaken(item *models.Inventory) bool {
+	for _, other := range f.items {
+		if other.ID != item.ID && strings.EqualFold(other.ItemName, item.ItemName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeInventory) Create(ctx context.Context, item *models.Inventory) error {
+	if f.nameTaken(item) {
+		return repository.ErrDuplicate
+	}
+	item.ID = f.nextID
+	item.CreatedAt = time.Now()
+	item.Version = 1
+	f.nextID++
+	f.items[item.ID] = *item
+	return nil
+}
+
+func (f *fakeInventory) Save(ctx context.Context, item *models.Inventory) error {
+	if f.nameTaken(item) {
+		return repository.ErrDuplicate
+	}
+	if f.items[item.ID].Version != item.Version {
+		return repository.ErrStale
+	}
+	item.Version++
+	f.items[item.ID] = *item
+	return nil
+}
+
+func (f *fakeInventory) Delete(ctx context.Context, item *models.Inventory) error {
+	if f.items[item.ID].Version != item.Version {
+		return repository.ErrStale
+	}
+	delete(f.items, item.ID)
+	return nil
+}
+
+func (f *fakeInventory) FindByNames(ctx context.Context, names []string) ([]models.Inventory, error) {
+	var items []models.Inventory
+	for _, item := range f.items {
+		for _, name := range names {
+			if strings.EqualFold(item.ItemName, name) {
+				items = append(items, item)
+			}
+		}
+	}
+	return items, nil
+}
+
+func (f *fakeInventory) Merge(ctx context.Context, from *models.Inventory, into *models.Inventory, recipes []models.Recipe) error {
+	delete(f.items, from.ID)
+	into.Version++
+	f.items[into.ID] = *into
+	for _, recipe := range recipes {
+		recipe.Version++
+		f.recipes.recipes[recipe.ID] = recipe
+	}
+	return nil
+}
+
+// fakeRecipes is an in-memory RecipeRepository for a single organization
+type fakeRecipes struct {
+	recipes map[uint]models.Recipe
+	nextID  uint
+}
+
+func newFakeRecipes() *fakeRecipes {
+	return &fakeRecipes{recipes: map[uint]models.Recipe{}, nextID: 1}
+}
+
+func (f *fakeRecipes) List(ctx context.Context, opts repository.ListOptions) ([]models.Recipe, int64, error) {
+	var matches []models.Recipe
+	for _, recipe := range f.recipes {
+		if strings.Contains(strings.ToLower(recipe.SKU), strings.ToLower(opts.Search)) {
+			matches = append(matches, recipe)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID > matches[j].ID })
+	return page(matches, opts), int64(len(matches)), nil
+}
+
+func (f *fakeRecipes) Get(ctx context.Context, id uint) (models.Recipe, error) {
+	recipe, ok := f.recipes[id]
+	if !ok {
+		return recipe, repository.ErrNotFound
+	}
+	return recipe, nil
+}
+
+func (f *fakeRecipes) Latest(ctx context.Context) (models.Recipe, error) {
+	latest, ok := f.recipes[f.nextID-1]
+	if !ok {
+		return latest, repository.ErrNotFound
+	}
+	return latest, nil
+}
+
+func (f *fakeRecipes) Create(ctx context.Context, recipe *models.Recipe) error {
+	recipe.ID = f.nextID
+	recipe.CreatedAt = time.Now()
+	recipe.Version = 1
+	f.nextID++
+	f.recipes[recipe.ID] = *recipe
+	return nil
+}
+
+func (f *fakeRecipes) Save(ctx context.Context, recipe *models.Recipe) error {
+	if f.recipes[recipe.ID].Version != recipe.Version {
+		return repository.ErrStale
+	}
+	recipe.Version++
+	f.recipes[recipe.ID] = *recipe
+	return nil
+}
+
+func (f *fakeRecipes) UsingIngredient(ctx context.Context, name string) ([]models.Recipe, error) {
+	var matches []models.Recipe
+	for _, recipe := range f.recipes {
+		var ingredients map[string]models.Measurement
+		json.Unmarshal(recipe.Ingredients, &ingredients)
+		for ingredient := range ingredients {
+			if strings.EqualFold(ingredient, name) {
+				matches = append(matches, recipe)
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+func page[T any](rows []T, opts repository.ListOptions) []T {
+	if opts.Offset >= len(rows) {
+		return nil
+	}
+	rows = rows[opts.Offset:]
+	if len(rows) > opts.Limit {
+		rows = rows[:opts.Limit]
+	}
+	return rows
+}
+
+// failingUsers is a UserRepository whose lookups fail, like a database that is down
+type failingUsers struct {
+	repository.UserRepository
+}
+
+func (failingUsers) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	return models.User{}, errors.New("database is down")
+}
+
+func (failingUsers) FindByID(ctx context.Context, id uint) (models.User, error) {
+	return models.User{}, errors.New("database is down")
+}
+
+// fakeAuth signs every request in as the owner of organization 1
+func fakeAuth(c *gin.Context) {
+	c.Set("user", "owner@example.com")
+	c.Set("user_id", uint(1))
+	c.Set("role", models.RoleOwner)
+	c.Set("organization_id", uint(1))
+	c.Next()
+}
+
+func TestInventoryAndRecipesWithFakes(t *testing.T) {
+	inventory, recipes := newFakeInventory(), newFakeRecipes()
+	inventory.recipes = recipes
+	h := &Handler{Inventory: inventory, Recipes: recipes}
+	r := newTestRouter(h, fakeAuth)
+
+	for _, item := range []map[string]interface{}{
+		{"item_name": "Milk", "quantity": 1, "uom": "Liter", "price_per_qty": 20000},
+		{"item_name": "Plastic Cup", "quantity": 50, "uom": "pcs", "price_per_qty": 25000},
+	} {
+		assert.Equal(t, 200, serve(r, "POST", "/inventory", item).Code)
+	}
+
+	w := serve(r, "GET", "/inventory?search=milk", nil)
+	assert.Equal(t, 200, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, float64(1), response["data"].(map[string]interface{})["total_items"])
+
+	w = serve(r, "POST", "/recipe", map[string]interface{}{
+		"number_of_cups": 2,
+		"ingredients": map[string]interface{}{
+			"Milk":        map[string]interface{}{"amount": 150, "unit": "ml"},
+			"Plastic Cup": map[string]interface{}{"amount": 1, "unit": "pcs"},
+		},
+	})
+	assert.Equal(t, 200, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, float64(7000), response["data"].(map[string]interface{})["cogs"])
+
+	w = serve(r, "POST", "/recipe", map[string]interface{}{
+		"number_of_cups": 1,
+		"ingredients": map[string]interface{}{
+			"Oat Milk": map[string]interface{}{"amount": 150, "unit": "ml"},
+		},
+	})
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, "VALIDATION_FAILED", errorCode(w))
+	assert.Contains(t, w.Body.String(), "ingredients[Oat Milk]")
+
+	w = serve(r, "POST", "/inventory", map[string]interface{}{"item_name": "milk", "quantity": 500, "uom": "ml", "price_per_qty": 9000})
+	assert.Equal(t, 409, w.Code)
+	assert.Equal(t, "INVENTORY_NAME_TAKEN", errorCode(w))
+
+	w = serve(r, "POST", "/inventory", map[string]interface{}{"item_name": "Fresh Milk", "quantity": 2, "uom": "liter", "price_per_qty": 36000})
+	assert.Equal(t, 200, w.Code)
+	w = serve(r, "POST", "/admin/inventory/3/merge", map[string]interface{}{"into_id": 1, "into_version": 1}, "If-Match", `"1"`)
+	assert.Equal(t, 200, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	merged := response["data"].(map[string]interface{})
+	assert.Equal(t, float64(3), merged["inventory"].(map[string]interface{})["quantity"])
+	assert.Equal(t, float64(0), merged["recipes_rewritten"])
+
+	assert.Equal(t, 404, serve(r, "PUT", "/inventory/42", map[string]interface{}{"quantity": 2}).Code)
+	// The merge bumped the kept item's version
+	assert.Equal(t, 412, serve(r, "DELETE", "/inventory/1", nil, "If-Match", `"1"`).Code)
+	assert.Equal(t, 200, serve(r, "DELETE", "/inventory/1", nil, "If-Match", `"2"`).Code)
+	assert.Equal(t, 404, serve(r, "DELETE", "/inventory/1", nil, "If-Match", `"2"`).Code)
+}
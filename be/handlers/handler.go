@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"be-test/config"
+	"be-test/repository"
+	"be-test/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Handler serves the API with the dependencies the server was started with
+type Handler struct {
+	Config config.Config
+	// DB backs login tokens, sessions, API keys and the email outbox. Sign-in
+	// reads users through Users and writes new ones in its own transaction.
+	DB        *gorm.DB
+	Inventory repository.InventoryRepository
+	Recipes   repository.RecipeRepository
+	Users     repository.UserRepository
+	// Mailer is the email transport, checked by the readiness probe
+	Mailer utils.Mailer
+}
+
+// New returns a Handler using db for storage and mailer for email
+func New(cfg config.Config, db *gorm.DB, mailer utils.Mailer) *Handler {
+	return &Handler{
+		Config:    cfg,
+		DB:        db,
+		Inventory: repository.NewInventoryRepository(db),
+		Recipes:   repository.NewRecipeRepository(db),
+		Users:     repository.NewUserRepository(db),
+		Mailer:    mailer,
+	}
+}
+
+// pagination reads the page, limit and search query parameters
+func pagination(c *gin.Context) (int, int, repository.ListOptions) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+
+	return page, limit, repository.ListOptions{
+		Offset: (page - 1) * limit,
+		Limit:  limit,
+		Search: c.DefaultQuery("search", ""),
+	}
+}
+
+// pathID returns the id path parameter, or 0 when it is not a number so the lookup finds nothing
+func pathID(c *gin.Context) uint {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
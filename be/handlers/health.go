@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"be-test/utils"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout bounds each dependency check of the readiness probe
+const readyTimeout = 2 * time.Second
+
+// Healthz reports that the process is up. It checks no dependencies, so an
+// unreachable database does not get the server restarted.
+func (h *Handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz reports whether the server can take traffic, by pinging the database
+// and the mail transport. It answers 503 when either of them fails.
+func (h *Handler) Readyz(c *gin.Context) {
+	checks := gin.H{}
+	ready := true
+	check := func(name string, ping func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+		defer cancel()
+
+		if err := ping(ctx); err != nil {
+			// The probe is public, so the cause is only logged
+			slog.WarnContext(ctx, "readiness check failed", "check", name, "error", err.Error())
+			checks[name] = "unavailable"
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	check("database", func(ctx context.Context) error {
+		sqlDB, err := h.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	if pinger, ok := h.Mailer.(utils.Pinger); ok {
+		check("mail", pinger.Ping)
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"be-test/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unreachableMailer is a mailer whose transport cannot be reached
+type unreachableMailer struct{ utils.Mailer }
+
+func (unreachableMailer) Ping(ctx context.Context) error {
+	return errors.New("dial tcp: connection refused")
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	r := setupTestRouter()
+
+	get := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	t.Run("Liveness", func(t *testing.T) {
+		code, response := get("/healthz")
+		assert.Equal(t, 200, code)
+		assert.Equal(t, "ok", response["status"])
+	})
+
+	t.Run("Readiness", func(t *testing.T) {
+		code, response := get("/readyz")
+		assert.Equal(t, 200, code)
+		assert.Equal(t, map[string]interface{}{"database": "ok", "mail": "ok"}, response["checks"])
+	})
+
+	t.Run("Readiness With Unreachable Mail Transport", func(t *testing.T) {
+		h := New(testConfig(), testDB, unreachableMailer{testOutbox})
+		r = newTestRouter(h, fakeAuth)
+
+		code, response := get("/readyz")
+		assert.Equal(t, 503, code)
+		assert.Equal(t, "unavailable", response["status"])
+		assert.Equal(t, map[string]interface{}{"database": "ok", "mail": "unavailable"}, response["checks"])
+	})
+}
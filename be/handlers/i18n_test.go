@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"be-test/apperror"
+	"be-test/i18n"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalizedMessages(t *testing.T) {
+	r := setupTestRouter()
+	token := loginAs(t, r, "i18n@example.com")
+
+	send := func(method string, path string, body interface{}, acceptLanguage string) map[string]interface{} {
+		return responseOf(serve(r, method, path, body, "Authorization", token, "Accept-Language", acceptLanguage))
+	}
+	message := func(response map[string]interface{}) map[string]interface{} {
+		return response["message"].(map[string]interface{})
+	}
+
+	t.Run("Catalogs Define The Same Messages", func(t *testing.T) {
+		for _, locale := range i18n.Locales() {
+			assert.Equal(t, i18n.IDs(i18n.DefaultLocale), i18n.IDs(locale), locale)
+			for _, code := range apperror.Codes() {
+				assert.True(t, i18n.Has(locale, "error."+code.Code), "%s has no message for %s", locale, code.Code)
+			}
+		}
+	})
+
+	t.Run("Accept-Language", func(t *testing.T) {
+		assert.Equal(t, "Berhasil mengambil data inventaris", message(send("GET", "/inventory", nil, ""))["success"])
+		assert.Equal(t, "Inventory retrieved successfully", message(send("GET", "/inventory", nil, "en-US,en;q=0.9"))["success"])
+		assert.Equal(t, "Item inventaris tidak ditemukan", message(send("DELETE", "/inventory/9999", nil, "id"))["warning"])
+	})
+
+	t.Run("Validation Errors", func(t *testing.T) {
+		response := responseOf(serve(r, "POST", "/auth/verify-code", `{"email": "i18n@example.com", "code": "12"}`, "Accept-Language", "en"))
+		fields := response["error"].(map[string]interface{})["fields"].(map[string]interface{})
+		assert.Equal(t, "must be exactly 6 characters long", fields["code"])
+	})
+
+	t.Run("User Preference Wins Over Accept-Language", func(t *testing.T) {
+		response := send("PUT", "/me/locale", `{"locale": "en"}`, "id")
+		assert.Equal(t, "Language updated successfully", message(response)["success"])
+		assert.Equal(t, "Inventory retrieved successfully", message(send("GET", "/inventory", nil, "id"))["success"])
+
+		response = send("PUT", "/me/locale", `{"locale": "fr"}`, "")
+		assert.Equal(t, "LOCALE_UNSUPPORTED", response["error"].(map[string]interface{})["code"])
+
+		send("PUT", "/me/locale", `{"locale": ""}`, "")
+		assert.Equal(t, "Berhasil mengambil data inventaris", message(send("GET", "/inventory", nil, ""))["success"])
+	})
+}
@@ -1,100 +1,238 @@
 package handler
 
 import (
-	"be-test/database"
+	"be-test/apperror"
 	"be-test/helpers"
 	"be-test/models"
-	"strconv"
+	"be-test/repository"
+	"errors"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetInventory(c *gin.Context) {
-	pageStr := c.DefaultQuery("page", "1")
-	limitStr := c.DefaultQuery("limit", "10")
-	search := c.DefaultQuery("search", "")
+// errNameTaken is reported when an item would share its name with another, ignoring case
+var errNameTaken = apperror.InventoryNameTaken.WithFields(map[string]string{"item_name": "field.name_taken"})
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 {
-		limit = 10
-	}
+// mergeInput names the item a duplicate is merged into, and the version of it
+// the client last read, as the If-Match header does for the duplicate
+type mergeInput struct {
+	IntoID      uint `json:"into_id" binding:"required"`
+	IntoVersion uint `json:"into_version" binding:"required"`
+}
 
-	offset := (page - 1) * limit
-	var inventory []models.Inventory
-	var totalItems int64
+func (h *Handler) GetInventory(c *gin.Context) {
+	page, limit, opts := pagination(c)
 
-	query := database.DB.Model(&models.Inventory{})
-	if search != "" {
-		query = query.Where("item_name ILIKE ?", "%"+search+"%")
+	inventory, totalItems, err := h.Inventory.List(c.Request.Context(), opts)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	query.Count(&totalItems)
-	query.Offset(offset).Limit(limit).Find(&inventory)
-
 	helpers.NewAPIResponse(c, gin.H{
 		"page":        page,
 		"limit":       limit,
 		"total_items": totalItems,
 		"total_pages": (totalItems + int64(limit) - 1) / int64(limit),
 		"inventory":   inventory,
-	}, nil, "", 0, "Inventory retrieved successfully")
+	}, nil, "", 0, "inventory.retrieved")
 }
 
-func AddInventory(c *gin.Context) {
-	var input models.Inventory
+// GetInventoryByID answers one item with its version as the ETag
+func (h *Handler) GetInventoryByID(c *gin.Context) {
+	item, err := h.Inventory.Get(c.Request.Context(), pathID(c))
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.InventoryNotFound))
+		return
+	}
+	if notModified(c, item.Version) {
+		return
+	}
+
+	helpers.NewAPIResponse(c, gin.H{"inventory": item}, nil, "", 0, "inventory.retrieved")
+}
+
+func (h *Handler) AddInventory(c *gin.Context) {
+	var input models.InventoryInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		helpers.NewAPIResponse(c, nil, err, "binding", 0, "Invalid input")
+		c.Error(apperror.FromBinding(err))
 		return
 	}
 
-	if err := database.DB.Create(&input).Error; err != nil {
-		helpers.NewAPIResponse(c, nil, err, "db", 0, "Failed to create inventory item")
+	item := input.Inventory()
+	if err := h.Inventory.Create(c.Request.Context(), &item); err != nil {
+		c.Error(apperror.WhenDuplicate(err, errNameTaken))
 		return
 	}
 
-	helpers.NewAPIResponse(c, gin.H{"inventory": input}, nil, "", 0, "Inventory item added successfully")
+	c.Header("ETag", etag(item.Version))
+	helpers.NewAPIResponse(c, gin.H{"inventory": item}, nil, "", 0, "inventory.added")
 }
 
-func UpdateInventory(c *gin.Context) {
-	var input models.Inventory
-	id := c.Param("id")
-
-	if err := database.DB.First(&input, id).Error; err != nil {
-		helpers.NewAPIResponse(c, nil, err, "db", 0, "Inventory item not found")
+// UpdateInventory replaces an item with the request body. Fields left out are
+// reset, and the ID, timestamps and version in the body are ignored.
+func (h *Handler) UpdateInventory(c *gin.Context) {
+	item, err := h.Inventory.Get(c.Request.Context(), pathID(c))
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.InventoryNotFound))
+		return
+	}
+	if !checkIfMatch(c, item.Version, gin.H{"inventory": item}) {
 		return
 	}
 
+	var input models.InventoryInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		helpers.NewAPIResponse(c, nil, err, "binding", 0, "Invalid input")
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	h.replaceInventory(c, item, input)
+}
+
+// PatchInventory changes the fields of an item given in a JSON Merge Patch body
+func (h *Handler) PatchInventory(c *gin.Context) {
+	item, err := h.Inventory.Get(c.Request.Context(), pathID(c))
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.InventoryNotFound))
+		return
+	}
+	if !checkIfMatch(c, item.Version, gin.H{"inventory": item}) {
+		return
+	}
+
+	var input models.InventoryInput
+	if !bindPatch(c, item, &input, inventoryPatchFields) {
+		return
+	}
+
+	h.replaceInventory(c, item, input)
+}
+
+// replaceInventory saves input in place of item, keeping item's identity
+func (h *Handler) replaceInventory(c *gin.Context, item models.Inventory, input models.InventoryInput) {
+	replaced := input.Inventory()
+	replaced.Model = item.Model
+	replaced.OrganizationID = item.OrganizationID
+	replaced.Version = item.Version
+
+	err := h.Inventory.Save(c.Request.Context(), &replaced)
+	if errors.Is(err, repository.ErrStale) {
+		h.staleInventory(c, item.ID)
+		return
+	}
+	if err != nil {
+		c.Error(apperror.WhenDuplicate(err, errNameTaken))
 		return
 	}
 
-	if err := database.DB.Save(&input).Error; err != nil {
-		helpers.NewAPIResponse(c, nil, err, "db", 0, "Failed to update inventory item")
+	c.Header("ETag", etag(replaced.Version))
+	helpers.NewAPIResponse(c, gin.H{"inventory": replaced}, nil, "", 0, "inventory.updated")
+}
+
+// staleInventory answers 412 with the item as another request just changed it
+func (h *Handler) staleInventory(c *gin.Context, id uint) {
+	item, err := h.Inventory.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.InventoryNotFound))
 		return
 	}
+	preconditionFailed(c, item.Version, gin.H{"inventory": item})
+}
 
-	helpers.NewAPIResponse(c, gin.H{"inventory": input}, nil, "", 0, "Inventory item updated successfully")
+func (h *Handler) DeleteInventory(c *gin.Context) {
+	inventory, err := h.Inventory.Get(c.Request.Context(), pathID(c))
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.InventoryNotFound))
+		return
+	}
+	if !checkIfMatch(c, inventory.Version, gin.H{"inventory": inventory}) {
+		return
+	}
+
+	err = h.Inventory.Delete(c.Request.Context(), &inventory)
+	if errors.Is(err, repository.ErrStale) {
+		h.staleInventory(c, inventory.ID)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, nil, nil, "", 0, "inventory.deleted")
 }
 
-func DeleteInventory(c *gin.Context) {
-	id := c.Param("id")
-	var inventory models.Inventory
+// MergeInventory folds a duplicate item into another: its stock is added to
+// the other item, recipes that use it are rewritten to use the other item, and
+// it is deleted. The recipes keep the COGS they were priced at. Both items
+// change, so it needs the duplicate's ETag in If-Match and the other item's
+// version as into_version. A stale into_version answers 412 with both items as
+// they are now, and it also fails with 412 when any of the rows changes while
+// it merges.
+func (h *Handler) MergeInventory(c *gin.Context) {
+	from, err := h.Inventory.Get(c.Request.Context(), pathID(c))
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.InventoryNotFound))
+		return
+	}
+	if !checkIfMatch(c, from.Version, gin.H{"inventory": from}) {
+		return
+	}
+
+	var input mergeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	if input.IntoID == from.ID {
+		c.Error(apperror.MergeSameItem)
+		return
+	}
+	into, err := h.Inventory.Get(c.Request.Context(), input.IntoID)
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.InventoryNotFound))
+		return
+	}
+	if input.IntoVersion != into.Version {
+		preconditionFailed(c, from.Version, gin.H{"inventory": from, "into": into})
+		return
+	}
+
+	if !into.Absorb(from) {
+		c.Error(apperror.MergeUnitMismatch.WithFields(map[string]string{"uom": "field.uom_mismatch"}))
+		return
+	}
 
-	if err := database.DB.First(&inventory, id).Error; err != nil {
-		helpers.NewAPIResponse(c, nil, err, "db", 0, "Inventory item not found")
+	recipes, err := h.Recipes.UsingIngredient(c.Request.Context(), from.ItemName)
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	for i := range recipes {
+		if _, err := recipes[i].RenameIngredient(from.ItemName, into.ItemName); err != nil {
+			if errors.Is(err, models.ErrUnitMismatch) {
+				err = apperror.MergeUnitMismatch.WithFields(map[string]string{"recipes[" + recipes[i].SKU + "]": "field.uom_mismatch"})
+			}
+			c.Error(err)
+			return
+		}
+	}
 
-	if err := database.DB.Delete(&inventory).Error; err != nil {
-		helpers.NewAPIResponse(c, nil, err, "db", 0, "Failed to delete inventory item")
+	err = h.Inventory.Merge(c.Request.Context(), &from, &into, recipes)
+	if errors.Is(err, repository.ErrStale) {
+		// Another request changed one of the items or recipes meanwhile, so merge again
+		c.Error(apperror.PreconditionFailed.Wrap(err))
+		return
+	}
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	helpers.NewAPIResponse(c, nil, nil, "", 0, "Inventory item deleted successfully")
+	c.Header("ETag", etag(into.Version))
+	helpers.NewAPIResponse(c, gin.H{
+		"inventory":         into,
+		"recipes_rewritten": len(recipes),
+	}, nil, "", 0, "inventory.merged")
 }
@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"be-test/database"
+	"be-test/models"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,6 +25,7 @@ func TestInventoryEndpoints(t *testing.T) {
 		"uom":           "Liter",
 		"price_per_qty": 5000,
 	}
+	var inventoryID uint
 
 	t.Run("Add Inventory", func(t *testing.T) {
 		jsonData, _ := json.Marshal(inventoryItem)
@@ -30,6 +36,32 @@ func TestInventoryEndpoints(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, 200, w.Code)
+
+		var response struct {
+			Data struct {
+				Inventory models.Inventory `json:"inventory"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		inventoryID = response.Data.Inventory.ID
+	})
+
+	t.Run("Add Inventory Ignores Its Identity In The Body", func(t *testing.T) {
+		w := serve(r, "POST", "/inventory", map[string]interface{}{
+			"ID": inventoryID, "CreatedAt": "2001-01-01T00:00:00Z", "DeletedAt": "2001-01-01T00:00:00Z", "version": 7,
+			"item_name": "Sparkling Water", "quantity": 1, "uom": "liter", "price_per_qty": 7000,
+		}, "Authorization", TestToken)
+		assert.Equal(t, 200, w.Code)
+
+		item := responseOf(w)["data"].(map[string]interface{})["inventory"].(map[string]interface{})
+		assert.NotEqual(t, float64(inventoryID), item["ID"])
+		assert.Nil(t, item["DeletedAt"])
+		assert.NotContains(t, item["CreatedAt"], "2001")
+		assert.Equal(t, float64(1), item["version"])
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+		w = serve(r, "GET", fmt.Sprintf("/inventory/%v", item["ID"]), nil, "Authorization", TestToken)
+		assert.Equal(t, 200, w.Code)
 	})
 
 	t.Run("Get Inventory", func(t *testing.T) {
@@ -41,23 +73,289 @@ func TestInventoryEndpoints(t *testing.T) {
 		assert.Equal(t, 200, w.Code)
 	})
 
+	t.Run("Search Inventory Ignores Case", func(t *testing.T) {
+		search := func(term string) float64 {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/inventory?search="+url.QueryEscape(term), nil)
+			req.Header.Set("Authorization", TestToken)
+			r.ServeHTTP(w, req)
+
+			var response map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			return response["data"].(map[string]interface{})["total_items"].(float64)
+		}
+
+		assert.Equal(t, float64(1), search("mINERAL wat"))
+		// LIKE wildcards in the term are matched literally
+		assert.Equal(t, float64(0), search("Mineral_Water"))
+		assert.Equal(t, float64(0), search("%"))
+	})
+
+	t.Run("Get Inventory From Another Organization", func(t *testing.T) {
+		otherToken := loginAs(t, r, "other-brand@example.com")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/inventory?search=Mineral Water", nil)
+		req.Header.Set("Authorization", otherToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, float64(0), data["total_items"])
+	})
+
+	t.Run("Add Inventory As Viewer", func(t *testing.T) {
+		loginAs(t, r, "viewer@example.com")
+		database.WithoutTenant(testDB).Model(&models.User{}).
+			Where("email = ?", "viewer@example.com").Update("role", models.RoleViewer)
+		viewerToken := loginAs(t, r, "viewer@example.com")
+
+		jsonData, _ := json.Marshal(inventoryItem)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/inventory", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", viewerToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 403, w.Code)
+	})
+
 	t.Run("Update Inventory", func(t *testing.T) {
 		jsonData, _ := json.Marshal(inventoryItem)
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("PUT", "/inventory/1", bytes.NewBuffer(jsonData))
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/inventory/%d", inventoryID), bytes.NewBuffer(jsonData))
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("Authorization", TestToken)
+		req.Header.Set("If-Match", `"1"`)
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, 200, w.Code)
 	})
 
+	t.Run("Get Inventory By ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/inventory/%d", inventoryID), nil)
+		req.Header.Set("Authorization", TestToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("Delete Inventory With An Old ETag", func(t *testing.T) {
+		for ifMatch, status := range map[string]int{"": 428, `"1"`: 412} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", fmt.Sprintf("/inventory/%d", inventoryID), nil)
+			req.Header.Set("Authorization", TestToken)
+			if ifMatch != "" {
+				req.Header.Set("If-Match", ifMatch)
+			}
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, status, w.Code)
+		}
+	})
+
 	t.Run("Delete Inventory", func(t *testing.T) {
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("DELETE", "/inventory/1", nil)
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/inventory/%d", inventoryID), nil)
 		req.Header.Set("Authorization", TestToken)
+		req.Header.Set("If-Match", `"2"`)
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, 200, w.Code)
 	})
 }
+
+func TestInventoryNamesAndMerge(t *testing.T) {
+	r := setupTestRouter()
+	token := loginAs(t, r, "merge@example.com")
+
+	send := func(method string, path string, body interface{}, ifMatch ...string) map[string]interface{} {
+		w := serve(r, method, path, body, "Authorization", token, "If-Match", strings.Join(ifMatch, ""))
+		response := responseOf(w)
+		response["status"] = w.Code
+		return response
+	}
+	addItem := func(item map[string]interface{}) uint {
+		response := send("POST", "/inventory", item)
+		if !assert.Equal(t, 200, response["status"]) {
+			t.FailNow()
+		}
+		data := response["data"].(map[string]interface{})
+		return uint(data["inventory"].(map[string]interface{})["ID"].(float64))
+	}
+	code := func(response map[string]interface{}) string {
+		return response["error"].(map[string]interface{})["code"].(string)
+	}
+
+	oatMilk := addItem(map[string]interface{}{"item_name": "Oat Milk", "quantity": 1, "uom": "liter", "price_per_qty": 30000})
+	oatly := addItem(map[string]interface{}{"item_name": "Oatly", "quantity": 500, "uom": "ml", "price_per_qty": 20000})
+	cups := addItem(map[string]interface{}{"item_name": "Cup", "quantity": 50, "uom": "pcs", "price_per_qty": 25000})
+
+	t.Run("Names Are Unique Ignoring Case", func(t *testing.T) {
+		response := send("POST", "/inventory", map[string]interface{}{"item_name": "OAT MILK", "quantity": 1, "uom": "liter", "price_per_qty": 1})
+		assert.Equal(t, 409, response["status"])
+		assert.Equal(t, "INVENTORY_NAME_TAKEN", code(response))
+		assert.Contains(t, response["error"].(map[string]interface{})["fields"], "item_name")
+
+		response = send("PUT", fmt.Sprintf("/inventory/%d", oatly), map[string]interface{}{"item_name": "oat milk", "quantity": 500, "uom": "ml", "price_per_qty": 20000}, `"1"`)
+		assert.Equal(t, 409, response["status"])
+		assert.Equal(t, "INVENTORY_NAME_TAKEN", code(response))
+	})
+
+	t.Run("Deleted Names Can Be Reused", func(t *testing.T) {
+		id := addItem(map[string]interface{}{"item_name": "Syrup", "quantity": 1, "uom": "liter", "price_per_qty": 1})
+		assert.Equal(t, 200, send("DELETE", fmt.Sprintf("/inventory/%d", id), nil, `"1"`)["status"])
+		addItem(map[string]interface{}{"item_name": "syrup", "quantity": 1, "uom": "liter", "price_per_qty": 1})
+	})
+
+	t.Run("Recipes Find Items In Any Case", func(t *testing.T) {
+		response := send("POST", "/recipe", map[string]interface{}{
+			"number_of_cups": 1,
+			"ingredients":    map[string]interface{}{"oatly": map[string]interface{}{"amount": 100, "unit": "ml"}},
+		})
+		assert.Equal(t, 200, response["status"])
+		response = send("POST", "/recipe", map[string]interface{}{
+			"number_of_cups": 1,
+			"ingredients": map[string]interface{}{
+				"Oat Milk": map[string]interface{}{"amount": 50, "unit": "ml"},
+				"Oatly":    map[string]interface{}{"amount": 0.1, "unit": "liter"},
+			},
+		})
+		assert.Equal(t, 200, response["status"])
+	})
+
+	t.Run("Merge Rejects", func(t *testing.T) {
+		response := send("POST", fmt.Sprintf("/admin/inventory/%d/merge", oatly), map[string]interface{}{"into_id": oatly, "into_version": 1}, `"1"`)
+		assert.Equal(t, 400, response["status"])
+		assert.Equal(t, "MERGE_SAME_ITEM", code(response))
+
+		response = send("POST", fmt.Sprintf("/admin/inventory/%d/merge", oatly), map[string]interface{}{"into_id": cups, "into_version": 1}, `"1"`)
+		assert.Equal(t, 422, response["status"])
+		assert.Equal(t, "MERGE_UNIT_MISMATCH", code(response))
+
+		response = send("POST", fmt.Sprintf("/admin/inventory/%d/merge", oatly), map[string]interface{}{"into_id": 999999, "into_version": 1}, `"1"`)
+		assert.Equal(t, 404, response["status"])
+	})
+
+	t.Run("Merge Needs Both Versions", func(t *testing.T) {
+		path := fmt.Sprintf("/admin/inventory/%d/merge", oatly)
+
+		response := send("POST", path, map[string]interface{}{"into_id": oatMilk, "into_version": 1})
+		assert.Equal(t, 428, response["status"])
+		assert.Equal(t, "PRECONDITION_REQUIRED", code(response))
+
+		response = send("POST", path, map[string]interface{}{"into_id": oatMilk, "into_version": 1}, `"2"`)
+		assert.Equal(t, 412, response["status"])
+		assert.Equal(t, "PRECONDITION_FAILED", code(response))
+
+		response = send("POST", path, map[string]interface{}{"into_id": oatMilk}, `"1"`)
+		assert.Equal(t, 400, response["status"])
+		assert.Contains(t, response["error"].(map[string]interface{})["fields"], "into_version")
+
+		response = send("POST", path, map[string]interface{}{"into_id": oatMilk, "into_version": 2}, `"1"`)
+		assert.Equal(t, 412, response["status"])
+		current := response["data"].(map[string]interface{})
+		assert.Equal(t, float64(1), current["into"].(map[string]interface{})["version"])
+		assert.Equal(t, float64(1), current["inventory"].(map[string]interface{})["version"])
+
+		// Nothing was merged
+		response = send("GET", "/inventory?search=oat", nil)
+		assert.Equal(t, float64(2), response["data"].(map[string]interface{})["total_items"])
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		response := send("POST", fmt.Sprintf("/admin/inventory/%d/merge", oatly), map[string]interface{}{"into_id": oatMilk, "into_version": 1}, `"1"`)
+		if !assert.Equal(t, 200, response["status"]) {
+			return
+		}
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, float64(2), data["recipes_rewritten"])
+		merged := data["inventory"].(map[string]interface{})
+		assert.Equal(t, 1.5, merged["quantity"])
+		assert.Equal(t, float64(50000), merged["price_per_qty"])
+
+		response = send("GET", "/inventory?search=oat", nil)
+		assert.Equal(t, float64(1), response["data"].(map[string]interface{})["total_items"])
+
+		response = send("GET", "/recipe", nil)
+		var uses []map[string]models.Measurement
+		for _, recipe := range response["data"].(map[string]interface{})["recipes"].([]interface{}) {
+			var ingredients map[string]models.Measurement
+			raw, _ := json.Marshal(recipe.(map[string]interface{})["ingredients"])
+			json.Unmarshal(raw, &ingredients)
+			uses = append(uses, ingredients)
+		}
+		assert.ElementsMatch(t, []map[string]models.Measurement{
+			{"Oat Milk": {Amount: 100, Unit: "ml"}},
+			{"Oat Milk": {Amount: 150, Unit: "ml"}},
+		}, uses)
+	})
+
+	t.Run("Merge Keeps The Price Of A Kilogram", func(t *testing.T) {
+		arabica := addItem(map[string]interface{}{"item_name": "Arabica", "quantity": 1, "uom": "kg", "price_per_qty": 350000})
+		beans := addItem(map[string]interface{}{"item_name": "Arabica Beans", "quantity": 1, "uom": "kg", "price_per_qty": 350000})
+		recipe := map[string]interface{}{
+			"number_of_cups": 1,
+			"ingredients":    map[string]interface{}{"Arabica Beans": map[string]interface{}{"amount": 0.5, "unit": "kg"}},
+		}
+		response := send("POST", "/recipe", recipe)
+		if !assert.Equal(t, 200, response["status"]) {
+			return
+		}
+		sku := response["data"].(map[string]interface{})["sku"]
+		assert.Equal(t, float64(175000), response["data"].(map[string]interface{})["cogs"])
+
+		response = send("POST", fmt.Sprintf("/admin/inventory/%d/merge", beans), map[string]interface{}{"into_id": arabica, "into_version": 1}, `"1"`)
+		if !assert.Equal(t, 200, response["status"]) {
+			return
+		}
+		merged := response["data"].(map[string]interface{})["inventory"].(map[string]interface{})
+		assert.Equal(t, float64(2), merged["quantity"])
+		assert.Equal(t, float64(700000), merged["price_per_qty"])
+
+		var saved models.Recipe
+		if err := database.WithoutTenant(testDB).Where("sku = ?", sku).First(&saved).Error; err != nil {
+			t.Fatal(err)
+		}
+		recipe["ingredients"] = map[string]interface{}{"Arabica": map[string]interface{}{"amount": 0.5, "unit": "kg"}}
+		response = send("PUT", fmt.Sprintf("/recipe/%d", saved.ID), recipe, etag(saved.Version))
+		assert.Equal(t, 200, response["status"])
+		assert.Equal(t, float64(175000), response["data"].(map[string]interface{})["cogs"])
+	})
+
+	t.Run("Merge As Manager", func(t *testing.T) {
+		loginAs(t, r, "merge-manager@example.com")
+		database.WithoutTenant(testDB).Model(&models.User{}).
+			Where("email = ?", "merge-manager@example.com").Update("role", models.RoleManager)
+		managerToken := loginAs(t, r, "merge-manager@example.com")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/admin/inventory/%d/merge", cups), bytes.NewBufferString(`{"into_id": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", managerToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 403, w.Code)
+	})
+
+	t.Run("Names Fold Every Letter", func(t *testing.T) {
+		addItem(map[string]interface{}{"item_name": "Crème", "quantity": 1, "uom": "liter", "price_per_qty": 60000})
+		response := send("POST", "/inventory", map[string]interface{}{"item_name": "CRÈME", "quantity": 1, "uom": "liter", "price_per_qty": 1})
+		assert.Equal(t, 409, response["status"])
+
+		response = send("POST", "/recipe", map[string]interface{}{
+			"number_of_cups": 1,
+			"ingredients":    map[string]interface{}{"CRÈME": map[string]interface{}{"amount": 100, "unit": "ml"}},
+		})
+		assert.Equal(t, 200, response["status"])
+		assert.Equal(t, float64(6000), response["data"].(map[string]interface{})["cogs"])
+
+		response = send("GET", "/inventory?search="+url.QueryEscape("ÈME"), nil)
+		assert.Equal(t, float64(1), response["data"].(map[string]interface{})["total_items"])
+	})
+}
@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"be-test/helpers"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys that verify access tokens, in the standard
+// JSON Web Key Set format so other services can verify tokens on their own.
+// New keys are published well before they sign, longer than the cache lasts.
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": helpers.JWKS()})
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"be-test/logging"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDAndLogging(t *testing.T) {
+	r := setupTestRouter()
+	token := loginAs(t, r, "logging@example.com")
+
+	t.Run("Request ID Is Echoed In Errors", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/inventory/9999", nil)
+		req.Header.Set("Authorization", token)
+		req.Header.Set("X-Request-ID", "checkout-42")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 404, w.Code)
+		assert.Equal(t, "checkout-42", w.Header().Get("X-Request-ID"))
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "checkout-42", response["request_id"])
+	})
+
+	t.Run("Invalid Request ID Is Replaced", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/healthz", nil)
+		req.Header.Set("X-Request-ID", "not a valid\nid")
+		r.ServeHTTP(w, req)
+
+		assert.Regexp(t, "^[0-9a-f]{32}$", w.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("Logs Carry Request ID And User But No Secrets", func(t *testing.T) {
+		var logs bytes.Buffer
+		previous := slog.Default()
+		slog.SetDefault(logging.New(&logs, slog.LevelDebug))
+		defer slog.SetDefault(previous)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/inventory?search=milk", nil)
+		req.Header.Set("Authorization", token)
+		req.Header.Set("X-Request-ID", "inventory-7")
+		r.ServeHTTP(w, req)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/auth/magic-link?token=super-secret-link", nil)
+		r.ServeHTTP(w, req)
+
+		var records []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+			var record map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("log line is not JSON: %s", line)
+			}
+			if record["msg"] == "request" {
+				records = append(records, record)
+			}
+		}
+
+		assert.Len(t, records, 2)
+		assert.Equal(t, "inventory-7", records[0]["request_id"])
+		assert.Equal(t, "/inventory", records[0]["route"])
+		assert.Equal(t, "search=milk", records[0]["query"])
+		assert.Equal(t, "logging@example.com", records[0]["user"])
+		assert.Equal(t, "token=%5BREDACTED%5D", records[1]["query"])
+		assert.NotContains(t, logs.String(), "super-secret-link")
+		assert.NotContains(t, logs.String(), strings.TrimPrefix(token, "Bearer "))
+	})
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"be-test/apperror"
+	"be-test/helpers"
+	"be-test/models"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	loginCodeTTL = time.Minute * 10
+
+	// A code is burned after loginCodeMaxAttempts wrong guesses
+	loginCodeMaxAttempts = 5
+
+	// Requesting new codes does not reset the guesses: at most
+	// loginCodeEmailAttempts are allowed per email per loginTokenWindow
+	loginCodeEmailAttempts = 10
+)
+
+// newLoginCode stores a 6-digit code for email and returns it
+func newLoginCode(db *gorm.DB, email string, ip string, userAgent string) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	loginCode := models.LoginCode{
+		Email:            email,
+		CodeHash:         hashLoginCode(email, code),
+		ExpiresAt:        time.Now().Add(loginCodeTTL),
+		RequestIP:        ip,
+		RequestUserAgent: userAgent,
+	}
+	if err := db.Create(&loginCode).Error; err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// consumeLoginCode checks code against the latest code sent to email. Wrong
+// guesses count against the code and the email until they lock; a new code
+// must then be requested, or the email waits out loginTokenWindow.
+func consumeLoginCode(db *gorm.DB, email string, code string) error {
+	var loginCode models.LoginCode
+	err := db.Where("email = ? AND consumed_at IS NULL AND expires_at > ?", email, time.Now()).
+		Order("created_at desc").First(&loginCode).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.LoginCodeInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	// Take a guess before comparing, in one conditional update, so concurrent
+	// guesses cannot all pass a check of a count read earlier
+	recentAttempts := db.Model(&models.LoginCode{}).Select("COALESCE(SUM(attempts), 0)").
+		Where("email = ? AND created_at > ?", email, time.Now().Add(-loginTokenWindow))
+	result := db.Model(&models.LoginCode{}).
+		Where("id = ? AND attempts < ? AND (?) < ?", loginCode.ID, loginCodeMaxAttempts, recentAttempts, loginCodeEmailAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return apperror.LoginCodeLocked
+	}
+
+	if subtle.ConstantTimeCompare([]byte(loginCode.CodeHash), []byte(hashLoginCode(email, code))) != 1 {
+		if loginCode.Attempts+1 >= loginCodeMaxAttempts {
+			return apperror.LoginCodeLocked
+		}
+		return apperror.LoginCodeInvalid
+	}
+
+	// Only one request may consume the code
+	result = db.Model(&models.LoginCode{}).
+		Where("id = ? AND consumed_at IS NULL", loginCode.ID).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return apperror.LoginCodeInvalid
+	}
+	return nil
+}
+
+// hashLoginCode binds the code to its email, since short codes repeat across users
+func hashLoginCode(email string, code string) string {
+	return helpers.HashToken(email + ":" + code)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"be-test/apperror"
+	"be-test/helpers"
+	"be-test/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	loginTokenTTL = time.Minute * 5
+
+	// At most loginTokenLimit links are sent to one email per loginTokenWindow
+	loginTokenLimit  = 5
+	loginTokenWindow = time.Minute * 15
+)
+
+// newLoginToken stores a single-use login token for email and returns its raw value
+func newLoginToken(db *gorm.DB, email string, ip string, userAgent string) (string, error) {
+	token, err := helpers.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	loginToken := models.LoginToken{
+		Email:            email,
+		TokenHash:        helpers.HashToken(token),
+		ExpiresAt:        time.Now().Add(loginTokenTTL),
+		RequestIP:        ip,
+		RequestUserAgent: userAgent,
+	}
+	if err := db.Create(&loginToken).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeLoginToken marks token as used and returns its email. The conditional
+// update lets exactly one of several concurrent requests succeed.
+func consumeLoginToken(db *gorm.DB, token string) (string, error) {
+	hash := helpers.HashToken(token)
+	now := time.Now()
+
+	result := db.Model(&models.LoginToken{}).
+		Where("token_hash = ? AND consumed_at IS NULL AND expires_at > ?", hash, now).
+		Update("consumed_at", now)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected != 1 {
+		return "", apperror.LoginTokenInvalid
+	}
+
+	var loginToken models.LoginToken
+	if err := db.Where("token_hash = ?", hash).First(&loginToken).Error; err != nil {
+		return "", err
+	}
+	return loginToken.Email, nil
+}
+
+// loginRateLimited reports whether email already received too many links recently
+func loginRateLimited(db *gorm.DB, email string) (bool, error) {
+	var count int64
+	err := db.Model(&models.LoginToken{}).
+		Where("email = ? AND created_at > ?", email, time.Now().Add(-loginTokenWindow)).
+		Count(&count).Error
+	return count >= loginTokenLimit, err
+}
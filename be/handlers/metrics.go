@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"be-test/apperror"
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var metricsHandler = promhttp.Handler()
+
+// Metrics serves the Prometheus metrics. When METRICS_TOKEN is set, scrapers
+// must send it as a Bearer token; it is only left empty in development.
+func (h *Handler) Metrics(c *gin.Context) {
+	if token := h.Config.MetricsToken; token != "" {
+		got := []byte(c.GetHeader("Authorization"))
+		if subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) != 1 {
+			c.Error(apperror.TokenRequired)
+			return
+		}
+	}
+
+	metricsHandler.ServeHTTP(c.Writer, c.Request)
+}
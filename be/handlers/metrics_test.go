@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"be-test/database"
+	"be-test/metrics"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// scrape returns the value of the sample matching series on /metrics, or 0
+// when it has not been recorded yet
+func scrape(t *testing.T, r *gin.Engine, series string) float64 {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("scraping metrics failed with status %d", w.Code)
+	}
+
+	match := regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(series) + ` (\S+)$`).FindSubmatch(w.Body.Bytes())
+	if match == nil {
+		return 0
+	}
+	value, _ := strconv.ParseFloat(string(match[1]), 64)
+	return value
+}
+
+// gauge gathers the named gauge from a registry holding only c
+func gauge(t *testing.T, c prometheus.Collector, name string) float64 {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Fatalf("gauge %s not found", name)
+	return 0
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	r := setupTestRouter()
+	token := loginAs(t, r, "metrics-owner@example.com")
+
+	t.Run("Requests Are Counted Per Route Template", func(t *testing.T) {
+		series := `http_requests_total{method="DELETE",route="/inventory/:id",status="404"}`
+		before := scrape(t, r, series)
+
+		serve(r, "DELETE", "/inventory/9998", nil, "Authorization", token)
+		serve(r, "DELETE", "/inventory/9999", nil, "Authorization", token)
+
+		assert.Equal(t, before+2, scrape(t, r, series))
+		assert.Greater(t, scrape(t, r, `db_query_duration_seconds_count{operation="query",table="inventories"}`), float64(0))
+	})
+
+	t.Run("Panics Are Counted", func(t *testing.T) {
+		r := setupTestRouter()
+		r.GET("/metrics-panic", func(c *gin.Context) { panic("boom") })
+		series := `http_requests_total{method="GET",route="/metrics-panic",status="500"}`
+		before := scrape(t, r, series)
+
+		assert.Equal(t, 500, serve(r, "GET", "/metrics-panic", nil).Code)
+		assert.Equal(t, before+1, scrape(t, r, series))
+	})
+
+	t.Run("Magic Link Sends Are Counted", func(t *testing.T) {
+		series := `email_sends_total{result="success",template="login"}`
+		// Deliver what earlier tests left in the outbox first
+		deliverEmails(t)
+		before := scrape(t, r, series)
+
+		jsonData, _ := json.Marshal(map[string]string{"email": "metrics-owner@example.com"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/auth/submit-email", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		deliverEmails(t)
+
+		assert.Equal(t, before+1, scrape(t, r, series))
+	})
+
+	t.Run("Low Stock And Stale COGS Gauges", func(t *testing.T) {
+		collector := metrics.NewBusinessCollector(database.WithoutTenant(testDB))
+		lowStock := gauge(t, collector, "inventory_low_stock_items")
+		stale := gauge(t, collector, "recipes_stale_cogs")
+
+		w := serve(r, "POST", "/inventory", map[string]interface{}{
+			"item_name": "Metrics Syrup", "quantity": 2, "uom": "liter", "price_per_qty": 80000, "reorder_level": 2,
+		}, "Authorization", token)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, lowStock+1, gauge(t, collector, "inventory_low_stock_items"))
+
+		w = serve(r, "POST", "/recipe", map[string]interface{}{
+			"number_of_cups": 1,
+			"ingredients":    map[string]interface{}{"Metrics Syrup": map[string]interface{}{"amount": 20, "unit": "ml"}},
+		}, "Authorization", token)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, stale, gauge(t, collector, "recipes_stale_cogs"))
+
+		response := responseOf(serve(r, "GET", "/inventory?search=Metrics Syrup", nil, "Authorization", token))
+		item := response["data"].(map[string]interface{})["inventory"].([]interface{})[0].(map[string]interface{})
+		item["price_per_qty"] = 90000
+		item["quantity"] = 5
+		w = serve(r, "PUT", "/inventory/"+strconv.Itoa(int(item["ID"].(float64))), item, "Authorization", token, "If-Match", fmt.Sprintf(`"%v"`, item["version"]))
+		assert.Equal(t, 200, w.Code)
+
+		assert.Equal(t, lowStock, gauge(t, collector, "inventory_low_stock_items"))
+		assert.Equal(t, stale+1, gauge(t, collector, "recipes_stale_cogs"))
+
+		// Ingredients match inventory items ignoring case
+		w = serve(r, "POST", "/recipe", map[string]interface{}{
+			"number_of_cups": 1,
+			"ingredients":    map[string]interface{}{"metrics syrup": map[string]interface{}{"amount": 30, "unit": "ml"}},
+		}, "Authorization", token)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, stale+1, gauge(t, collector, "recipes_stale_cogs"))
+	})
+
+	t.Run("Metrics Token", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.MetricsToken = "scrape-secret"
+		r := newTestRouter(New(cfg, testDB, testOutbox), fakeAuth)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 401, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("Authorization", "Bearer scrape-secret")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+	})
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"be-test/helpers"
+	"be-test/models"
+	"be-test/openapi"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+var specJSON = sync.OnceValues(func() ([]byte, error) {
+	return json.Marshal(OpenAPISpec())
+})
+
+// OpenAPI serves the OpenAPI 3 document of the API
+func (h *Handler) OpenAPI(c *gin.Context) {
+	spec, err := specJSON()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", spec)
+}
+
+// Docs serves an interactive API reference rendered from /openapi.json
+func (h *Handler) Docs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+// DocsAsset serves the file of swagger-ui-dist named by the last segment of
+// the route. The files are embedded in the binary, so the docs page loads no
+// third-party script and works offline.
+func (h *Handler) DocsAsset(c *gin.Context) {
+	c.FileFromFS(path.Base(c.FullPath()), http.FS(swaggerFiles.FS))
+}
+
+// endpoint documents one route of route.SetupRoutes
+type endpoint struct {
+	method  string
+	path    string
+	id      string
+	summary string
+	tag     string
+	// permission is required by the route, "" when any signed-in caller may use it
+	permission models.Permission
+	// public routes need no credentials
+	public bool
+	// ifMatch routes change a versioned resource and require its ETag in If-Match
+	ifMatch bool
+	query   []openapi.Parameter
+	// body is bound from the JSON request body, nil when there is none
+	body interface{}
+	// patch lists the fields of body a JSON Merge Patch may change, nil when body is bound whole
+	patch []string
+	// data is the schema of the envelope's data on success, nil for none
+	data *openapi.Schema
+	// raw replaces the envelope for routes that do not answer with APIResponseNew
+	raw *openapi.Response
+}
+
+// OpenAPISpec documents every route of route.SetupRoutes. Keep it in sync when
+// adding a route; the route tests fail when the two drift apart.
+func OpenAPISpec() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "Iced Coffee Recipe Management API",
+		Version:     "1.0.0",
+		Description: "Error responses carry a stable code in error.code, see the README for the list.",
+	})
+	doc.Components.SecuritySchemes["bearerAuth"] = &openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	doc.Components.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key"}
+
+	str := &openapi.Schema{Type: "string"}
+	integer := &openapi.Schema{Type: "integer"}
+	number := &openapi.Schema{Type: "number"}
+	object := func(key string, v interface{}) *openapi.Schema {
+		return openapi.Object(map[string]*openapi.Schema{key: doc.Schema(v)})
+	}
+	page := func(key string, item interface{}) *openapi.Schema {
+		return openapi.Object(map[string]*openapi.Schema{
+			"page":        integer,
+			"limit":       integer,
+			"total_items": integer,
+			"total_pages": integer,
+			key:           openapi.ArrayOf(doc.Schema(item)),
+		})
+	}
+	tokens := openapi.Object(map[string]*openapi.Schema{
+		"access_token":  str,
+		"refresh_token": str,
+		"expires_in":    integer,
+	})
+	recipeSummary := openapi.Object(map[string]*openapi.Schema{
+		"sku":            str,
+		"cogs":           number,
+		"number_of_cups": integer,
+	})
+	listQuery := []openapi.Parameter{
+		{Name: "page", In: "query", Schema: integer},
+		{Name: "limit", In: "query", Schema: integer},
+		{Name: "search", In: "query", Description: "Case-insensitive substring to filter by", Schema: str},
+	}
+	status := openapi.Object(map[string]*openapi.Schema{"status": str, "checks": {Type: "object", AdditionalProperties: str}})
+
+	endpoints := []endpoint{
+		{method: "GET", path: "/healthz", id: "healthz", summary: "Liveness probe", tag: "Health", public: true,
+			raw: &openapi.Response{Description: "The process is up", Content: openapi.JSON(status)}},
+		{method: "GET", path: "/readyz", id: "readyz", summary: "Readiness probe, 503 when the database or mail transport is down", tag: "Health", public: true,
+			raw: &openapi.Response{Description: "The server can take traffic", Content: openapi.JSON(status)}},
+		{method: "GET", path: "/metrics", id: "metrics", summary: "Prometheus metrics, behind METRICS_TOKEN when it is set", tag: "Health", public: true,
+			raw: &openapi.Response{Description: "Metrics in the Prometheus text format", Content: map[string]*openapi.MediaType{"text/plain": {Schema: str}}}},
+		{method: "GET", path: "/openapi.json", id: "openapi", summary: "This OpenAPI document", tag: "Docs", public: true,
+			raw: &openapi.Response{Description: "OpenAPI 3 document", Content: openapi.JSON(&openapi.Schema{Type: "object"})}},
+		{method: "GET", path: "/docs", id: "docs", summary: "Interactive API reference", tag: "Docs", public: true,
+			raw: &openapi.Response{Description: "HTML page", Content: map[string]*openapi.MediaType{"text/html": {Schema: str}}}},
+		{method: "GET", path: "/docs/swagger-ui.css", id: "docsStyle", summary: "Stylesheet of the API reference", tag: "Docs", public: true,
+			raw: &openapi.Response{Description: "CSS", Content: map[string]*openapi.MediaType{"text/css": {Schema: str}}}},
+		{method: "GET", path: "/docs/swagger-ui-bundle.js", id: "docsScript", summary: "Script of the API reference", tag: "Docs", public: true,
+			raw: &openapi.Response{Description: "JavaScript", Content: map[string]*openapi.MediaType{"text/javascript": {Schema: str}}}},
+		{method: "GET", path: "/.well-known/jwks.json", id: "jwks", summary: "Public keys that verify access tokens", tag: "Authentication", public: true,
+			raw: &openapi.Response{Description: "JSON Web Key Set", Content: openapi.JSON(object("keys", []helpers.JWK{}))}},
+
+		{method: "POST", path: "/auth/submit-email", id: "submitEmail", summary: "Email a magic link and sign-in code", tag: "Authentication", public: true,
+			body: struct {
+				Email string `json:"email" binding:"required,email"`
+			}{}},
+		{method: "GET", path: "/auth/magic-link", id: "magicLink", summary: "Sign in with a magic link token", tag: "Authentication", public: true,
+			query: []openapi.Parameter{{Name: "token", In: "query", Required: true, Schema: str}}, data: tokens},
+		{method: "POST", path: "/auth/verify-code", id: "verifyCode", summary: "Sign in with the emailed 6-digit code", tag: "Authentication", public: true,
+			body: verifyCodeInput{}, data: tokens},
+		{method: "POST", path: "/auth/refresh", id: "refreshToken", summary: "Exchange a refresh token for a new token pair", tag: "Authentication", public: true,
+			body: refreshInput{}, data: tokens},
+		{method: "POST", path: "/auth/logout", id: "logout", summary: "Revoke the current session, 403 SESSION_REQUIRED with an API key", tag: "Authentication"},
+		{method: "POST", path: "/auth/logout-all", id: "logoutAll", summary: "Revoke every session of the current user, 403 SESSION_REQUIRED with an API key", tag: "Authentication"},
+		{method: "PUT", path: "/me/locale", id: "updateLocale", summary: "Set the language of the current user's messages", tag: "Users",
+			body: localeInput{}, data: object("user", models.User{})},
+
+		{method: "GET", path: "/inventory", id: "getInventory", summary: "List inventory items", tag: "Inventory",
+			permission: models.PermissionInventoryRead, query: listQuery, data: page("inventory", models.Inventory{})},
+		{method: "GET", path: "/inventory/:id", id: "getInventoryItem", summary: "Get an inventory item, with its version as the ETag", tag: "Inventory",
+			permission: models.PermissionInventoryRead, data: object("inventory", models.Inventory{})},
+		{method: "POST", path: "/inventory", id: "addInventory", summary: "Add an inventory item", tag: "Inventory",
+			permission: models.PermissionInventoryWrite, body: models.InventoryInput{}, data: object("inventory", models.Inventory{})},
+		{method: "PUT", path: "/inventory/:id", id: "updateInventory", summary: "Replace an inventory item", tag: "Inventory",
+			permission: models.PermissionInventoryWrite, ifMatch: true, body: models.InventoryInput{}, data: object("inventory", models.Inventory{})},
+		{method: "PATCH", path: "/inventory/:id", id: "patchInventory", summary: "Change some fields of an inventory item", tag: "Inventory",
+			permission: models.PermissionInventoryWrite, ifMatch: true, body: models.InventoryInput{}, patch: inventoryPatchFields, data: object("inventory", models.Inventory{})},
+		{method: "DELETE", path: "/inventory/:id", id: "deleteInventory", summary: "Delete an inventory item", tag: "Inventory",
+			permission: models.PermissionInventoryWrite, ifMatch: true},
+		{method: "POST", path: "/admin/inventory/:id/merge", id: "mergeInventory", summary: "Merge a duplicate inventory item into another, moving its stock and recipe uses", tag: "Inventory",
+			permission: models.PermissionInventoryMerge, ifMatch: true, body: mergeInput{},
+			data: openapi.Object(map[string]*openapi.Schema{"inventory": doc.Schema(models.Inventory{}), "recipes_rewritten": integer})},
+
+		{method: "POST", path: "/recipe", id: "addRecipe", summary: "Create a recipe and price it from the inventory", tag: "Recipes",
+			permission: models.PermissionRecipeBrew, body: models.RecipeInput{}, data: recipeSummary},
+		{method: "GET", path: "/recipe", id: "getRecipes", summary: "List recipes", tag: "Recipes",
+			permission: models.PermissionRecipeRead, query: listQuery, data: page("recipes", models.Recipe{})},
+		{method: "GET", path: "/recipe/:id", id: "getRecipe", summary: "Get a recipe, with its version as the ETag", tag: "Recipes",
+			permission: models.PermissionRecipeRead, data: object("recipe", models.Recipe{})},
+		{method: "PUT", path: "/recipe/:id", id: "updateRecipe", summary: "Replace a recipe's cups and ingredients and price it again", tag: "Recipes",
+			permission: models.PermissionRecipeBrew, ifMatch: true, body: models.RecipeInput{}, data: recipeSummary},
+		{method: "PATCH", path: "/recipe/:id", id: "patchRecipe", summary: "Change a recipe's cups or ingredients and price it again", tag: "Recipes",
+			permission: models.PermissionRecipeBrew, ifMatch: true, body: models.RecipeInput{}, patch: recipePatchFields, data: recipeSummary},
+
+		{method: "GET", path: "/users", id: "getUsers", summary: "List users in the organization", tag: "Users",
+			permission: models.PermissionUsersManage, query: listQuery, data: page("users", models.User{})},
+		{method: "POST", path: "/users/invitations", id: "inviteUser", summary: "Invite an email with a role", tag: "Users",
+			permission: models.PermissionUsersManage, body: struct {
+				Email string      `json:"email" binding:"required,email"`
+				Role  models.Role `json:"role" binding:"required"`
+			}{}, data: object("invitation", models.Invitation{})},
+		{method: "PUT", path: "/users/:id/role", id: "updateUserRole", summary: "Change a user's role", tag: "Users",
+			permission: models.PermissionUsersManage, body: roleInput{}, data: object("user", models.User{})},
+		{method: "POST", path: "/users/:id/deactivate", id: "deactivateUser", summary: "Deactivate a user", tag: "Users",
+			permission: models.PermissionUsersManage, data: object("user", models.User{})},
+		{method: "POST", path: "/users/:id/activate", id: "activateUser", summary: "Reactivate a user", tag: "Users",
+			permission: models.PermissionUsersManage, data: object("user", models.User{})},
+
+		{method: "GET", path: "/api-keys", id: "getAPIKeys", summary: "List API keys", tag: "API Keys",
+			permission: models.PermissionAPIKeysManage, data: openapi.Object(map[string]*openapi.Schema{"api_keys": doc.Schema([]models.APIKey{})})},
+		{method: "POST", path: "/api-keys", id: "createAPIKey", summary: "Create an API key, shown only in this response", tag: "API Keys",
+			permission: models.PermissionAPIKeysManage, body: apiKeyInput{},
+			data: openapi.Object(map[string]*openapi.Schema{"api_key": doc.Schema(models.APIKey{}), "key": str})},
+		{method: "DELETE", path: "/api-keys/:id", id: "revokeAPIKey", summary: "Revoke an API key", tag: "API Keys",
+			permission: models.PermissionAPIKeysManage},
+
+		{method: "GET", path: "/admin/emails", id: "getOutboundEmails", summary: "List emails sent to the organization", tag: "Email Outbox",
+			permission: models.PermissionEmailsManage, query: []openapi.Parameter{
+				{Name: "page", In: "query", Schema: integer},
+				{Name: "limit", In: "query", Schema: integer},
+				{Name: "status", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{models.EmailPending, models.EmailSending, models.EmailSent, models.EmailDead}}},
+			}, data: page("emails", models.OutboundEmail{})},
+		{method: "POST", path: "/admin/emails/:id/retry", id: "retryOutboundEmail", summary: "Queue a failed email again", tag: "Email Outbox",
+			permission: models.PermissionEmailsManage, data: object("email", models.OutboundEmail{})},
+	}
+
+	envelope := doc.Schema(helpers.APIResponseNew{})
+	errorResponse := func(description string) *openapi.Response {
+		return &openapi.Response{Description: description, Content: openapi.JSON(envelope)}
+	}
+
+	for _, e := range endpoints {
+		op := &openapi.Operation{
+			OperationID: e.id,
+			Summary:     e.summary,
+			Tags:        []string{e.tag},
+			Parameters:  e.query,
+			Responses:   map[string]*openapi.Response{},
+		}
+
+		if e.raw != nil {
+			op.Responses["200"] = e.raw
+		} else {
+			data := e.data
+			if data == nil {
+				data = &openapi.Schema{Nullable: true}
+			}
+			op.Responses["200"] = &openapi.Response{Description: "Success", Content: openapi.JSON(&openapi.Schema{
+				AllOf: []*openapi.Schema{envelope, openapi.Object(map[string]*openapi.Schema{"data": data})},
+			})}
+			op.Responses["500"] = errorResponse("Unexpected server error")
+		}
+
+		if e.body != nil {
+			content := openapi.JSON(doc.Schema(e.body))
+			if e.patch != nil {
+				content = doc.MergePatch(e.body, e.patch...)
+			}
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: content}
+			op.Responses["400"] = errorResponse("Invalid input")
+		}
+		if strings.Contains(e.path, "/:") {
+			op.Responses["404"] = errorResponse("Not found")
+		}
+		if e.ifMatch {
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name: "If-Match", In: "header", Required: true, Schema: str,
+				Description: "The ETag of the resource as last read, such as \"3\"",
+			})
+			op.Responses["412"] = errorResponse("The resource has changed, data holds its current version")
+			op.Responses["428"] = errorResponse("If-Match is missing")
+		}
+		if !e.public {
+			op.Security = []map[string][]string{{"bearerAuth": {}}, {"apiKey": {}}}
+			op.Responses["401"] = errorResponse("Missing or invalid credentials")
+		}
+		if e.permission != "" {
+			op.Summary += " (requires " + string(e.permission) + ")"
+			op.Responses["403"] = errorResponse("The caller lacks the permission")
+		}
+
+		doc.Add(e.method, e.path, op)
+	}
+
+	return doc
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIEndpoints(t *testing.T) {
+	r := setupTestRouter()
+
+	t.Run("Spec", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/openapi.json", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+
+		var spec struct {
+			OpenAPI    string                                       `json:"openapi"`
+			Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+			Components struct {
+				Schemas map[string]map[string]interface{} `json:"schemas"`
+			} `json:"components"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+		assert.Equal(t, "3.0.3", spec.OpenAPI)
+		assert.Contains(t, spec.Paths["/inventory/{id}"], "put")
+		for _, name := range []string{"Inventory", "RecipeInput", "Measurement", "APIResponseNew", "APIError"} {
+			assert.Contains(t, spec.Components.Schemas, name)
+		}
+
+		inventory := spec.Components.Schemas["Inventory"]["properties"].(map[string]interface{})
+		assert.Contains(t, inventory, "item_name")
+		assert.NotContains(t, inventory, "OrganizationID")
+	})
+
+	t.Run("Docs", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/docs", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), "/openapi.json")
+		// The page loads nothing from other origins
+		assert.NotContains(t, w.Body.String(), "https://")
+
+		for asset, contentType := range map[string]string{"/docs/swagger-ui.css": "text/css", "/docs/swagger-ui-bundle.js": "javascript"} {
+			w := serve(r, "GET", asset, nil)
+			assert.Equal(t, 200, w.Code, asset)
+			assert.Contains(t, w.Header().Get("Content-Type"), contentType, asset)
+			assert.NotEmpty(t, w.Body.Bytes(), asset)
+		}
+	})
+}
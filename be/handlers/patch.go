@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"be-test/apperror"
+	"be-test/helpers"
+	"encoding/json"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// The fields PATCH may change. ID, timestamps and anything derived, such as a
+// recipe's SKU and COGS, are left out so clients cannot overwrite them.
+var (
+	inventoryPatchFields = []string{"item_name", "quantity", "uom", "price_per_qty", "reorder_level"}
+	recipePatchFields    = []string{"number_of_cups", "ingredients"}
+)
+
+// bindPatch applies the JSON Merge Patch (RFC 7386) in the request body to
+// current and binds the result to patched, checking its binding rules. Only
+// the top-level fields in allowed may be patched. It reports the error and
+// returns false when the patch is refused.
+func bindPatch(c *gin.Context, current interface{}, patched interface{}, allowed []string) bool {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return false
+	}
+	if fields == nil {
+		// A patch that is not an object would replace the whole resource
+		c.Error(apperror.InvalidJSON)
+		return false
+	}
+
+	readOnly := map[string]string{}
+	for name := range fields {
+		if !slices.Contains(allowed, name) {
+			readOnly[name] = "field.read_only"
+		}
+	}
+	if len(readOnly) > 0 {
+		c.Error(apperror.Validation.WithFields(readOnly))
+		return false
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	merged, err := helpers.MergePatch(doc, body)
+	if err != nil {
+		c.Error(apperror.FromBinding(err))
+		return false
+	}
+
+	if err := json.Unmarshal(merged, patched); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return false
+	}
+	if err := binding.Validator.ValidateStruct(patched); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"be-test/database"
+	"be-test/models"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialUpdates(t *testing.T) {
+	r := setupTestRouter()
+	token := loginAs(t, r, "patch@example.com")
+
+	// send changes resources like a client would, with the ETag it last read
+	send := func(method string, path string, body string) *httptest.ResponseRecorder {
+		var ifMatch string
+		if method == "PUT" || method == "PATCH" {
+			ifMatch = serve(r, "GET", path, nil, "Authorization", token).Header().Get("ETag")
+		}
+		return serve(r, method, path, body, "Authorization", token, "Content-Type", "application/merge-patch+json", "If-Match", ifMatch)
+	}
+	inventoryOf := func(w *httptest.ResponseRecorder) models.Inventory {
+		var response struct {
+			Data struct {
+				Inventory models.Inventory `json:"inventory"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data.Inventory
+	}
+
+	w := send("POST", "/inventory", `{"item_name": "Patch Beans", "quantity": 2, "uom": "kg", "price_per_qty": 400000, "reorder_level": 1}`)
+	assert.Equal(t, 200, w.Code)
+	beans := inventoryOf(w)
+	w = send("POST", "/inventory", `{"item_name": "Patch Milk", "quantity": 1, "uom": "liter", "price_per_qty": 20000}`)
+	assert.Equal(t, 200, w.Code)
+
+	t.Run("Patch Inventory", func(t *testing.T) {
+		w := send("PATCH", fmt.Sprintf("/inventory/%d", beans.ID), `{"price_per_qty": 420000, "reorder_level": null}`)
+		assert.Equal(t, 200, w.Code)
+
+		item := inventoryOf(w)
+		assert.Equal(t, beans.ID, item.ID)
+		assert.Equal(t, "Patch Beans", item.ItemName)
+		assert.Equal(t, float64(2), item.Quantity)
+		assert.Equal(t, float64(420000), item.PricePerQty)
+		assert.Equal(t, float64(0), item.ReorderLevel)
+		assert.True(t, item.CreatedAt.Equal(beans.CreatedAt))
+	})
+
+	t.Run("Patch Read-only Fields", func(t *testing.T) {
+		w := send("PATCH", fmt.Sprintf("/inventory/%d", beans.ID), `{"ID": 99, "CreatedAt": "2020-01-01T00:00:00Z", "quantity": 5}`)
+		assert.Equal(t, 400, w.Code)
+		assert.Equal(t, "VALIDATION_FAILED", errorCode(w))
+		assert.Contains(t, errorFields(w), "ID")
+		assert.Contains(t, errorFields(w), "CreatedAt")
+		assert.NotContains(t, errorFields(w), "quantity")
+	})
+
+	t.Run("Patch Breaking A Rule", func(t *testing.T) {
+		w := send("PATCH", fmt.Sprintf("/inventory/%d", beans.ID), `{"uom": null}`)
+		assert.Equal(t, 400, w.Code)
+		assert.Contains(t, errorFields(w), "uom")
+
+		w = send("PATCH", fmt.Sprintf("/inventory/%d", beans.ID), `{"item_name": "patch milk"}`)
+		assert.Equal(t, 409, w.Code)
+	})
+
+	t.Run("Patch Is Not An Object", func(t *testing.T) {
+		w := send("PATCH", fmt.Sprintf("/inventory/%d", beans.ID), `[{"op": "replace", "path": "/quantity", "value": 1}]`)
+		assert.Equal(t, 400, w.Code)
+		assert.Equal(t, "INVALID_JSON", errorCode(w))
+	})
+
+	t.Run("Put Replaces The Whole Item", func(t *testing.T) {
+		w := send("PUT", fmt.Sprintf("/inventory/%d", beans.ID), `{"ID": 99, "item_name": "Patch Beans", "quantity": 3, "uom": "kg", "price_per_qty": 500000}`)
+		assert.Equal(t, 200, w.Code)
+		item := inventoryOf(w)
+		assert.Equal(t, beans.ID, item.ID)
+		assert.Equal(t, float64(3), item.Quantity)
+
+		w = send("PUT", fmt.Sprintf("/inventory/%d", beans.ID), `{"quantity": 4}`)
+		assert.Equal(t, 400, w.Code)
+		assert.Contains(t, errorFields(w), "item_name")
+	})
+
+	t.Run("Patch Recipe", func(t *testing.T) {
+		w := send("POST", "/recipe", `{"number_of_cups": 1, "ingredients": {"Patch Beans": {"amount": 20, "unit": "g"}, "Patch Milk": {"amount": 100, "unit": "ml"}}}`)
+		assert.Equal(t, 200, w.Code)
+		var response struct {
+			Data struct {
+				SKU string `json:"sku"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		w = send("GET", "/recipe?search="+response.Data.SKU, "")
+		var list struct {
+			Data struct {
+				Recipes []models.Recipe `json:"recipes"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &list)
+		if !assert.Len(t, list.Data.Recipes, 1) {
+			return
+		}
+		path := fmt.Sprintf("/recipe/%d", list.Data.Recipes[0].ID)
+
+		// 2 cups of 20 g of beans at 500000 for 3 kg
+		w = send("PATCH", path, `{"number_of_cups": 2, "ingredients": {"Patch Milk": null}}`)
+		assert.Equal(t, 200, w.Code)
+		var patched map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &patched)
+		data := patched["data"].(map[string]interface{})
+		assert.Equal(t, response.Data.SKU, data["sku"])
+		assert.Equal(t, float64(2), data["number_of_cups"])
+		assert.InDelta(t, 2*20*500000/3000.0, data["cogs"], 0.01)
+
+		w = send("PATCH", path, `{"ingredients": {"Patch Beans": {"amount": 0}}}`)
+		assert.Equal(t, 400, w.Code)
+		assert.Contains(t, errorFields(w), "ingredients[Patch Beans].amount")
+
+		w = send("PATCH", path, `{"ingredients": {"Oat Milk": {"amount": 100, "unit": "ml"}}}`)
+		assert.Equal(t, 400, w.Code)
+		assert.Contains(t, errorFields(w), "ingredients[Oat Milk]")
+
+		w = send("PATCH", path, `{"sku": "IC-00000000-001", "cogs": 1}`)
+		assert.Equal(t, 400, w.Code)
+		assert.Contains(t, errorFields(w), "sku")
+		assert.Contains(t, errorFields(w), "cogs")
+
+		// An empty patch prices a recipe saved under older prices again
+		database.WithoutTenant(testDB).Model(&models.Recipe{}).Where("id = ?", list.Data.Recipes[0].ID).Update("cogs", 1)
+		w = send("PATCH", path, `{}`)
+		assert.Equal(t, 200, w.Code)
+		json.Unmarshal(w.Body.Bytes(), &patched)
+		data = patched["data"].(map[string]interface{})
+		assert.InDelta(t, 2*20*500000/3000.0, data["cogs"], 0.01)
+	})
+}
@@ -1,31 +1,35 @@
 package handler
 
 import (
-	"be-test/database"
+	"be-test/apperror"
 	"be-test/helpers"
 	"be-test/models"
+	"be-test/repository"
+	"be-test/tracing"
+	"be-test/validation"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/datatypes"
-	"gorm.io/gorm"
 )
 
-func AddRecipe(c *gin.Context) {
+func (h *Handler) AddRecipe(c *gin.Context) {
 	var input models.RecipeInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		helpers.NewAPIResponse(c, nil, err, "binding", 0, "Invalid input")
+	if !h.bindRecipe(c, &input) {
 		return
 	}
 
 	// Convert ingredients map to JSON
 	ingredientsJSON, err := json.Marshal(input.Ingredients)
 	if err != nil {
-		helpers.NewAPIResponse(c, nil, err, "json", 0, "Failed to marshal ingredients")
+		c.Error(err)
 		return
 	}
 
@@ -38,15 +42,14 @@ func AddRecipe(c *gin.Context) {
 	ingredients := make(map[string]models.Measurement)
 	json.Unmarshal(ingredientsJSON, &ingredients)
 
-	recipe.COGS, err = calculateCOGSWithSubquery(ingredients, input.NumberOfCups, database.DB)
+	recipe.COGS, err = calculateCOGS(c.Request.Context(), h.Inventory, ingredients, input.NumberOfCups)
 	if err != nil {
-		helpers.NewAPIResponse(c, nil, err, "db", 0, "Failed to calculate COGS")
+		c.Error(err)
 		return
 	}
 	// Generate SKU
 	currentTime := time.Now()
-	var lastRecipe models.Recipe
-	database.DB.Order("created_at desc").First(&lastRecipe)
+	lastRecipe, _ := h.Recipes.Latest(c.Request.Context())
 
 	sequence := 1
 	if !lastRecipe.CreatedAt.IsZero() && lastRecipe.CreatedAt.Format("20060102") == currentTime.Format("20060102") {
@@ -56,125 +59,206 @@ func AddRecipe(c *gin.Context) {
 
 	recipe.SKU = fmt.Sprintf("IC-%s-%03d", currentTime.Format("20060102"), sequence)
 
-	if err := database.DB.Create(&recipe).Error; err != nil {
-		helpers.NewAPIResponse(c, nil, err, "db", 0, "Failed to save recipe")
+	if err := h.Recipes.Create(c.Request.Context(), &recipe); err != nil {
+		c.Error(err)
 		return
 	}
 
+	c.Header("ETag", etag(recipe.Version))
 	helpers.NewAPIResponse(c, gin.H{
 		"sku":            recipe.SKU,
 		"cogs":           recipe.COGS,
 		"number_of_cups": recipe.NumberOfCups,
-	}, nil, "", 0, "Recipe added successfully")
+	}, nil, "", 0, "recipe.added")
 }
 
-func GetRecipe(c *gin.Context) {
-	pageStr := c.DefaultQuery("page", "1")
-	limitStr := c.DefaultQuery("limit", "10")
-	search := c.DefaultQuery("search", "")
+func (h *Handler) GetRecipe(c *gin.Context) {
+	page, limit, opts := pagination(c)
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 {
-		limit = 10
-	}
-
-	offset := (page - 1) * limit
-	var recipes []models.Recipe
-	var totalItems int64
-
-	query := database.DB.Model(&models.Recipe{})
-	if search != "" {
-		query = query.Where("sku ILIKE ?", "%"+search+"%")
+	recipes, totalItems, err := h.Recipes.List(c.Request.Context(), opts)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	query.Count(&totalItems)
-	query.Offset(offset).Limit(limit).Order("id desc").Find(&recipes)
-
 	helpers.NewAPIResponse(c, gin.H{
 		"page":        page,
 		"limit":       limit,
 		"total_items": totalItems,
 		"total_pages": (totalItems + int64(limit) - 1) / int64(limit),
 		"recipes":     recipes,
-	}, nil, "", 0, "Recipes retrieved successfully")
+	}, nil, "", 0, "recipe.retrieved")
+}
+
+// GetRecipeByID answers one recipe with its version as the ETag
+func (h *Handler) GetRecipeByID(c *gin.Context) {
+	recipe, err := h.Recipes.Get(c.Request.Context(), pathID(c))
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.RecipeNotFound))
+		return
+	}
+	if notModified(c, recipe.Version) {
+		return
+	}
+
+	helpers.NewAPIResponse(c, gin.H{"recipe": recipe}, nil, "", 0, "recipe.retrieved")
 }
 
-func UpdateRecipe(c *gin.Context) {
-	id := c.Param("id")
-	var recipe models.Recipe
+// UpdateRecipe replaces the cups and ingredients of a recipe and prices it again
+func (h *Handler) UpdateRecipe(c *gin.Context) {
+	recipe, err := h.Recipes.Get(c.Request.Context(), pathID(c))
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.RecipeNotFound))
+		return
+	}
+	if !checkIfMatch(c, recipe.Version, gin.H{"recipe": recipe}) {
+		return
+	}
+
 	var input models.RecipeInput
+	if !h.bindRecipe(c, &input) {
+		return
+	}
+
+	h.repriceRecipe(c, recipe, input)
+}
+
+// PatchRecipe changes the cups or ingredients of a recipe given in a JSON
+// Merge Patch body and prices it again. Ingredients are merged by name, so
+// {"ingredients": {"Milk": null}} drops only the milk.
+func (h *Handler) PatchRecipe(c *gin.Context) {
+	recipe, err := h.Recipes.Get(c.Request.Context(), pathID(c))
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.RecipeNotFound))
+		return
+	}
+	if !checkIfMatch(c, recipe.Version, gin.H{"recipe": recipe}) {
+		return
+	}
 
-	if err := database.DB.First(&recipe, id).Error; err != nil {
-		helpers.NewAPIResponse(c, nil, err, "db", 0, "Recipe not found")
+	current := models.RecipeInput{NumberOfCups: recipe.NumberOfCups}
+	if err := json.Unmarshal(recipe.Ingredients, &current.Ingredients); err != nil {
+		c.Error(err)
 		return
 	}
 
-	if err := c.ShouldBindJSON(&input); err != nil {
-		helpers.NewAPIResponse(c, nil, err, "binding", 0, "Invalid input")
+	var input models.RecipeInput
+	if !bindPatch(c, current, &input, recipePatchFields) || !h.checkIngredients(c, &input) {
 		return
 	}
 
+	h.repriceRecipe(c, recipe, input)
+}
+
+// repriceRecipe saves input as the cups and ingredients of recipe with its new COGS
+func (h *Handler) repriceRecipe(c *gin.Context, recipe models.Recipe, input models.RecipeInput) {
 	// Convert ingredients map to JSON
 	ingredientsJSON, err := json.Marshal(input.Ingredients)
 	if err != nil {
-		helpers.NewAPIResponse(c, nil, err, "json", 0, "Failed to marshal ingredients")
+		c.Error(err)
 		return
 	}
 
 	// Recalculate COGS
-	ingredients := make(map[string]models.Measurement)
-	json.Unmarshal(ingredientsJSON, &ingredients)
 	recipe.NumberOfCups = input.NumberOfCups
 	recipe.Ingredients = datatypes.JSON(ingredientsJSON)
-	recipe.COGS, err = calculateCOGSWithSubquery(ingredients, input.NumberOfCups, database.DB)
+	recipe.COGS, err = calculateCOGS(c.Request.Context(), h.Inventory, input.Ingredients, input.NumberOfCups)
 	if err != nil {
-		helpers.NewAPIResponse(c, nil, err, "db", 0, "Failed to calculate COGS")
+		c.Error(err)
 		return
 	}
 
-	if err := database.DB.Save(&recipe).Error; err != nil {
-		helpers.NewAPIResponse(c, nil, err, "db", 0, "Failed to update recipe")
+	err = h.Recipes.Save(c.Request.Context(), &recipe)
+	if errors.Is(err, repository.ErrStale) {
+		h.staleRecipe(c, recipe.ID)
+		return
+	}
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
+	c.Header("ETag", etag(recipe.Version))
 	helpers.NewAPIResponse(c, gin.H{
 		"sku":            recipe.SKU,
 		"cogs":           recipe.COGS,
 		"number_of_cups": recipe.NumberOfCups,
-	}, nil, "", 0, "Recipe updated successfully")
+	}, nil, "", 0, "recipe.updated")
 }
 
-func calculateCOGSWithSubquery(ingredients map[string]models.Measurement, numberOfCups int, db *gorm.DB) (float64, error) {
-	var totalCOGS float64
+// staleRecipe answers 412 with the recipe as another request just changed it
+func (h *Handler) staleRecipe(c *gin.Context, id uint) {
+	recipe, err := h.Recipes.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.RecipeNotFound))
+		return
+	}
+	preconditionFailed(c, recipe.Version, gin.H{"recipe": recipe})
+}
 
-	// Process each ingredient with individual optimized query
+// bindRecipe binds a recipe from the request body and checks that its
+// ingredients are in the inventory. It reports the error and returns false when not.
+func (h *Handler) bindRecipe(c *gin.Context, input *models.RecipeInput) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return false
+	}
+	return h.checkIngredients(c, input)
+}
+
+// checkIngredients checks that the ingredients of input are in the inventory.
+// It reports the error and returns false when not.
+func (h *Handler) checkIngredients(c *gin.Context, input *models.RecipeInput) bool {
+	items, err := h.Inventory.FindByNames(c.Request.Context(), input.IngredientNames())
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	if err := validation.Struct(validation.WithInventory(c.Request.Context(), items), input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return false
+	}
+	return true
+}
+
+// calculateCOGS prices numberOfCups of a recipe from the current inventory prices
+func calculateCOGS(ctx context.Context, inventory repository.InventoryRepository, ingredients map[string]models.Measurement, numberOfCups int) (cogs float64, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "calculateCOGS", trace.WithAttributes(
+		attribute.Int("recipe.ingredients", len(ingredients)),
+		attribute.Int("recipe.number_of_cups", numberOfCups),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	names := make([]string, 0, len(ingredients))
+	for itemName := range ingredients {
+		names = append(names, itemName)
+	}
+
+	// Load every ingredient in one query instead of one per item
+	items, err := inventory.FindByNames(ctx, names)
+	if err != nil {
+		return 0, err
+	}
+	// Item names are unique ignoring case, so recipes may spell them in any case
+	byName := make(map[string]models.Inventory, len(items))
+	for _, item := range items {
+		byName[strings.ToLower(item.ItemName)] = item
+	}
+
+	var totalCOGS float64
 	for itemName, measurement := range ingredients {
-		var itemCost float64
-		unit := strings.ToLower(measurement.Unit)
-
-		query := db.Model(&models.Inventory{}).
-			Select("CASE ? "+
-				"WHEN 'g' THEN (? * price_per_qty) / (quantity * 1000) "+
-				"WHEN 'kg' THEN ? * price_per_qty "+
-				"WHEN 'ml' THEN (? * price_per_qty) / (quantity * 1000) "+
-				"WHEN 'liter' THEN ? * price_per_qty "+
-				"WHEN 'pcs' THEN CASE WHEN quantity > 0 THEN ? * (price_per_qty / quantity) ELSE 0 END "+
-				"ELSE 0 END as cost",
-				unit, measurement.Amount, measurement.Amount,
-				measurement.Amount, measurement.Amount, measurement.Amount).
-			Where("item_name = ?", itemName)
-
-		if err := query.Scan(&itemCost).Error; err != nil {
-			return 0, fmt.Errorf("item %s not found or invalid", itemName)
+		item, ok := byName[strings.ToLower(itemName)]
+		if !ok {
+			return 0, apperror.IngredientNotFound.WithFields(map[string]string{
+				"ingredients[" + itemName + "]": "field.not_in_inventory",
+			})
 		}
 
+		itemCost := item.Cost(measurement)
 		if itemCost == 0 {
-			return 0, fmt.Errorf("invalid unit or calculation for %s", itemName)
+			return 0, apperror.UnitIncompatible.WithFields(map[string]string{
+				"ingredients[" + itemName + "].unit": "field.unit_incompatible",
+			})
 		}
 
 		totalCOGS += itemCost * float64(numberOfCups)
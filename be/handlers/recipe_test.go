@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"be-test/database"
+	"be-test/models"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -43,6 +46,28 @@ func TestRecipeEndpoints(t *testing.T) {
 		},
 	}
 
+	t.Run("Add Ingredients", func(t *testing.T) {
+		for _, item := range []map[string]interface{}{
+			{"item_name": "Aren Sugar", "quantity": 1, "uom": "kg", "price_per_qty": 60000},
+			{"item_name": "Milk", "quantity": 1, "uom": "liter", "price_per_qty": 30000},
+			{"item_name": "Ice Cube", "quantity": 1, "uom": "kg", "price_per_qty": 15000},
+			{"item_name": "Plastic Cup", "quantity": 25, "uom": "pcs", "price_per_qty": 12500},
+			{"item_name": "Coffee Bean", "quantity": 1, "uom": "kg", "price_per_qty": 350000},
+			{"item_name": "Mineral Water", "quantity": 1, "uom": "liter", "price_per_qty": 1000},
+		} {
+			jsonData, _ := json.Marshal(item)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/inventory", bytes.NewBuffer(jsonData))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", TestToken)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, 200, w.Code)
+		}
+	})
+
+	var recipeID uint
+
 	t.Run("Add Recipe", func(t *testing.T) {
 		jsonData, _ := json.Marshal(recipeData)
 		w := httptest.NewRecorder()
@@ -58,6 +83,10 @@ func TestRecipeEndpoints(t *testing.T) {
 		data := response["data"].(map[string]interface{})
 		assert.NotNil(t, data["sku"])
 		assert.Equal(t, float64(13250), data["cogs"])
+
+		var recipe models.Recipe
+		database.WithoutTenant(testDB).Order("id desc").First(&recipe)
+		recipeID = recipe.ID
 	})
 
 	t.Run("Get Recipe", func(t *testing.T) {
@@ -73,16 +102,68 @@ func TestRecipeEndpoints(t *testing.T) {
 		recipeData["number_of_cups"] = 2
 		jsonData, _ := json.Marshal(recipeData)
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("PUT", "/recipe/1", bytes.NewBuffer(jsonData))
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/recipe/%d", recipeID), bytes.NewBuffer(jsonData))
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("Authorization", TestToken)
+		req.Header.Set("If-Match", `"1"`)
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
 
 		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
 		data := response["data"].(map[string]interface{})
 		assert.Equal(t, float64(26500), data["cogs"])
 	})
+
+	t.Run("Update Recipe Needs Its ETag", func(t *testing.T) {
+		update := func(ifMatch string) *httptest.ResponseRecorder {
+			jsonData, _ := json.Marshal(recipeData)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", fmt.Sprintf("/recipe/%d", recipeID), bytes.NewBuffer(jsonData))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", TestToken)
+			if ifMatch != "" {
+				req.Header.Set("If-Match", ifMatch)
+			}
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		w := update("")
+		assert.Equal(t, 428, w.Code)
+		assert.Equal(t, "PRECONDITION_REQUIRED", errorCode(w))
+
+		// Another client updated the recipe after this one read version 1
+		w = update(`"1"`)
+		assert.Equal(t, 412, w.Code)
+		assert.Equal(t, "PRECONDITION_FAILED", errorCode(w))
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+		var response struct {
+			Data struct {
+				Recipe models.Recipe `json:"recipe"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, recipeID, response.Data.Recipe.ID)
+		assert.Equal(t, uint(2), response.Data.Recipe.Version)
+		assert.Equal(t, 2, response.Data.Recipe.NumberOfCups)
+	})
+
+	t.Run("Get Recipe By ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/recipe/%d", recipeID), nil)
+		req.Header.Set("Authorization", TestToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+		w = httptest.NewRecorder()
+		req.Header.Set("If-None-Match", `"2"`)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 304, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
 }
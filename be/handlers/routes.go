@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"be-test/metrics"
+	"be-test/middleware"
+	"be-test/models"
+	"be-test/tracing"
+	"be-test/validation"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// Routes sets up all the API routes served by h on router, authenticating
+// callers with auth. It lives here rather than in package route so the
+// handler tests serve the same routes and middleware as the server.
+func (h *Handler) Routes(router *gin.Engine, auth gin.HandlerFunc) {
+	// The bodies bound below rely on the API's own binding rules
+	validation.Register()
+
+	// metrics.HTTP runs outside Recovery so panics are counted as the 500s they become
+	router.Use(middleware.RequestID(), tracing.HTTP(), middleware.RequestLogger(), metrics.HTTP(), middleware.Recovery(), middleware.Errors())
+
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", "X-API-Key", "X-Request-ID", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID", "ETag"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	// Liveness and readiness probes
+	router.GET("/healthz", h.Healthz)
+	router.GET("/readyz", h.Readyz)
+	router.GET("/metrics", h.Metrics)
+
+	// API documentation
+	router.GET("/openapi.json", h.OpenAPI)
+	router.GET("/docs", h.Docs)
+	router.GET("/docs/swagger-ui.css", h.DocsAsset)
+	router.GET("/docs/swagger-ui-bundle.js", h.DocsAsset)
+
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", h.JWKS)
+
+	// Authentication Routes
+	router.POST("/auth/submit-email", h.SubmitEmail)
+	router.GET("/auth/magic-link", h.MagicLink)
+	router.POST("/auth/verify-code", h.VerifyCode)
+	router.POST("/auth/refresh", h.RefreshToken)
+
+	// Protected Routes (requires JWT authentication)
+	protected := router.Group("/")
+	protected.Use(auth)
+
+	protected.POST("/auth/logout", middleware.RequireSession(), h.Logout)
+	protected.POST("/auth/logout-all", middleware.RequireSession(), h.LogoutAll)
+	protected.PUT("/me/locale", h.UpdateLocale)
+
+	// Inventory Routes
+	protected.GET("/inventory", middleware.RequirePermission(models.PermissionInventoryRead), h.GetInventory)
+	protected.GET("/inventory/:id", middleware.RequirePermission(models.PermissionInventoryRead), h.GetInventoryByID)
+	protected.POST("/inventory", middleware.RequirePermission(models.PermissionInventoryWrite), h.AddInventory)
+	protected.PUT("/inventory/:id", middleware.RequirePermission(models.PermissionInventoryWrite), h.UpdateInventory)
+	protected.PATCH("/inventory/:id", middleware.RequirePermission(models.PermissionInventoryWrite), h.PatchInventory)
+	protected.DELETE("/inventory/:id", middleware.RequirePermission(models.PermissionInventoryWrite), h.DeleteInventory)
+
+	// Recipe Routes
+	protected.POST("/recipe", middleware.RequirePermission(models.PermissionRecipeBrew), h.AddRecipe)
+	protected.GET("/recipe", middleware.RequirePermission(models.PermissionRecipeRead), h.GetRecipe)
+	protected.GET("/recipe/:id", middleware.RequirePermission(models.PermissionRecipeRead), h.GetRecipeByID)
+	protected.PUT("/recipe/:id", middleware.RequirePermission(models.PermissionRecipeBrew), h.UpdateRecipe)
+	protected.PATCH("/recipe/:id", middleware.RequirePermission(models.PermissionRecipeBrew), h.PatchRecipe)
+
+	// User Management Routes
+	users := protected.Group("/users")
+	users.Use(middleware.RequirePermission(models.PermissionUsersManage))
+	users.GET("", h.GetUsers)
+	users.POST("/invitations", h.InviteUser)
+	users.PUT("/:id/role", h.UpdateUserRole)
+	users.POST("/:id/deactivate", h.DeactivateUser)
+	users.POST("/:id/activate", h.ActivateUser)
+
+	// API Key Routes
+	apiKeys := protected.Group("/api-keys")
+	apiKeys.Use(middleware.RequirePermission(models.PermissionAPIKeysManage))
+	apiKeys.GET("", h.GetAPIKeys)
+	apiKeys.POST("", h.CreateAPIKey)
+	apiKeys.DELETE("/:id", h.RevokeAPIKey)
+
+	// Inventory Admin Routes
+	protected.POST("/admin/inventory/:id/merge", middleware.RequirePermission(models.PermissionInventoryMerge), h.MergeInventory)
+
+	// Email Outbox Routes
+	emails := protected.Group("/admin/emails")
+	emails.Use(middleware.RequirePermission(models.PermissionEmailsManage))
+	emails.GET("", h.GetOutboundEmails)
+	emails.POST("/:id/retry", h.RetryOutboundEmail)
+}
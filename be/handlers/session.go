@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"be-test/apperror"
+	"be-test/database"
+	"be-test/helpers"
+	"be-test/models"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	accessTokenTTL  = time.Minute * 15
+	refreshTokenTTL = time.Hour * 24 * 30
+)
+
+type refreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// issueSession opens a session for user and returns a fresh token pair
+func issueSession(c *gin.Context, db *gorm.DB, user models.User) (gin.H, error) {
+	refreshToken, err := helpers.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: helpers.HashToken(refreshToken),
+		UserAgent:        c.Request.UserAgent(),
+		IP:               c.ClientIP(),
+		ExpiresAt:        now.Add(refreshTokenTTL),
+		LastUsedAt:       now,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return tokenPair(user, session, refreshToken)
+}
+
+func tokenPair(user models.User, session models.Session, refreshToken string) (gin.H, error) {
+	accessToken, err := helpers.GenerateAccessToken(user.Email, string(user.Role), session.ID, time.Now().Add(accessTokenTTL).Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new token pair and rotates it
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var input refreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	db := database.WithoutTenant(h.DB.WithContext(c.Request.Context()))
+	hash := helpers.HashToken(input.RefreshToken)
+
+	var session models.Session
+	err := db.Where("refresh_token_hash = ?", hash).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// A rotated-out token being replayed means it leaked, so end the session
+		if db.Where("previous_token_hash = ?", hash).First(&session).Error == nil {
+			revokeSessions(db.Where("id = ?", session.ID))
+		}
+		c.Error(apperror.RefreshTokenInvalid)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !session.Active() {
+		c.Error(apperror.RefreshTokenInvalid)
+		return
+	}
+
+	user, err := h.Users.FindByID(c.Request.Context(), session.UserID)
+	if err != nil || user.DeactivatedAt != nil {
+		c.Error(apperror.UserInactive)
+		return
+	}
+
+	refreshToken, err := helpers.GenerateOpaqueToken()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Only the request that still holds the current hash wins a concurrent refresh
+	result := db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  helpers.HashToken(refreshToken),
+			"previous_token_hash": hash,
+			"last_used_at":        time.Now(),
+		})
+	if result.Error != nil {
+		c.Error(result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(apperror.RefreshTokenInvalid)
+		return
+	}
+
+	tokens, err := tokenPair(user, session, refreshToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, tokens, nil, "authenticated", 0, "auth.refreshed")
+}
+
+// Logout revokes the session of the current access token
+func (h *Handler) Logout(c *gin.Context) {
+	db := database.WithoutTenant(h.DB.WithContext(c.Request.Context()))
+	if err := revokeSessions(db.Where("id = ?", c.GetUint("session_id"))); err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, nil, nil, "", 0, "auth.logged_out")
+}
+
+// LogoutAll revokes every session of the current user
+func (h *Handler) LogoutAll(c *gin.Context) {
+	db := database.WithoutTenant(h.DB.WithContext(c.Request.Context()))
+	if err := revokeSessions(db.Where("user_id = ?", c.GetUint("user_id"))); err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, nil, nil, "", 0, "auth.logged_out_all")
+}
+
+// revokeSessions marks the sessions matched by query as revoked
+func revokeSessions(query *gorm.DB) error {
+	return query.Model(&models.Session{}).Where("revoked_at IS NULL").Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"be-test/config"
+	"be-test/database"
+	"be-test/helpers"
+	"be-test/middleware"
+	"be-test/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// testDB is the database of the router built by setupTestRouter
+var testDB *gorm.DB
+
+// testOutbox collects the emails sent during tests instead of using SMTP
+var testOutbox = utils.NewOutboxMailer("")
+
+// deliverEmails runs the email worker once so queued emails reach testOutbox
+func deliverEmails(t *testing.T) {
+	t.Helper()
+
+	worker := utils.NewEmailWorker(testDB, testOutbox)
+	if err := worker.ProcessDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testConfig loads the settings from the environment or be/.env, and points
+// the database at a fresh SQLite file so tests need no database server
+func testConfig() config.Config {
+	_, b, _, _ := runtime.Caller(0)
+	var args []string
+	if envFile := filepath.Join(filepath.Dir(b), "..", config.DefaultFile); fileExists(envFile) {
+		args = []string{"-config", envFile}
+	}
+
+	cfg, _, err := config.Load(args)
+	if err != nil {
+		panic(err)
+	}
+
+	testDBPathOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "be-test")
+		if err != nil {
+			panic(err)
+		}
+		testDBPath = filepath.Join(dir, "test.db")
+	})
+	cfg.Database = config.Database{Driver: "sqlite", Path: testDBPath}
+	return cfg
+}
+
+var signingKeysOnce sync.Once
+
+// testDBPath is the SQLite file shared by the tests of one run
+var (
+	testDBPath     string
+	testDBPathOnce sync.Once
+)
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func setupTestRouter() *gin.Engine {
+	cfg := testConfig()
+
+	// Initialize database using existing setup
+	testDB = database.InitTest(cfg.Database)
+	// Key IDs have a one second resolution, so reloading could replace the key
+	// that signed the tokens of an earlier test
+	signingKeysOnce.Do(func() {
+		if err := helpers.LoadSigningKeys(""); err != nil {
+			panic(err)
+		}
+	})
+
+	return newTestRouter(New(cfg, testDB, testOutbox), middleware.AuthMiddleware(testDB))
+}
+
+// newTestRouter serves the routes of Handler.Routes for h, authenticating callers with auth
+func newTestRouter(h *Handler, auth gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h.Routes(r, auth)
+	return r
+}
+
+// loginAs signs in through the magic link flow and returns a bearer token for email
+func loginAs(t *testing.T, r *gin.Engine, email string) string {
+	t.Helper()
+
+	data := login(t, r, email)
+	return "Bearer " + data["access_token"].(string)
+}
+
+// login signs in through the magic link flow and returns the issued token pair
+func login(t *testing.T, r *gin.Engine, email string) map[string]interface{} {
+	t.Helper()
+
+	token, err := newLoginToken(database.WithoutTenant(testDB), email, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/magic-link?token="+token, nil)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login as %s failed with status %d", email, w.Code)
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response["data"].(map[string]interface{})
+}
+
+// serve sends a request to r and records the response. A string body is sent
+// as is, any other body is encoded as JSON. headers are pairs of name and
+// value; pairs with an empty value are left out.
+func serve(r http.Handler, method string, path string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(body)
+	default:
+		jsonData, _ := json.Marshal(body)
+		reader = bytes.NewReader(jsonData)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		if headers[i+1] != "" {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// responseOf decodes the JSON body of a response
+func responseOf(w *httptest.ResponseRecorder) map[string]interface{} {
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	r := setupTestRouter()
+	token := loginAs(t, r, "tracing-owner@example.com")
+
+	spans := tracetest.NewInMemoryExporter()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	assert.Equal(t, 200, serve(r, "POST", "/inventory", map[string]interface{}{
+		"item_name": "Tracing Beans", "quantity": 1, "uom": "kg", "price_per_qty": 200000,
+	}, "Authorization", token).Code)
+
+	t.Run("Recipe Save Continues The Incoming Trace", func(t *testing.T) {
+		spans.Reset()
+		traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+		w := serve(r, "POST", "/recipe", map[string]interface{}{
+			"number_of_cups": 1,
+			"ingredients":    map[string]interface{}{"Tracing Beans": map[string]interface{}{"amount": 18, "unit": "g"}},
+		}, "Authorization", token, "traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		assert.Equal(t, 200, w.Code)
+
+		byName := map[string]tracetest.SpanStub{}
+		for _, span := range spans.GetSpans() {
+			assert.Equal(t, traceID, span.SpanContext.TraceID().String(), span.Name)
+			byName[span.Name] = span
+		}
+
+		server, ok := byName["POST /recipe"]
+		assert.True(t, ok)
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+
+		cogs, ok := byName["calculateCOGS"]
+		assert.True(t, ok)
+		assert.Equal(t, server.SpanContext.SpanID(), cogs.Parent.SpanID())
+
+		query, ok := byName["db.select inventories"]
+		assert.True(t, ok)
+		assert.Equal(t, cogs.SpanContext.SpanID(), query.Parent.SpanID())
+		for _, attr := range query.Attributes {
+			if attr.Key == "db.query.text" {
+				assert.NotContains(t, attr.Value.AsString(), "Tracing Beans")
+			}
+		}
+
+		_, ok = byName["db.create recipes"]
+		assert.True(t, ok)
+	})
+
+	t.Run("Email Sends Are Traced", func(t *testing.T) {
+		// Deliver what earlier tests left in the outbox first
+		deliverEmails(t)
+		spans.Reset()
+
+		jsonData, _ := json.Marshal(map[string]string{"email": "tracing-owner@example.com"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/auth/submit-email", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		deliverEmails(t)
+
+		var names []string
+		for _, span := range spans.GetSpans() {
+			names = append(names, span.Name)
+		}
+		assert.Contains(t, strings.Join(names, ","), "email.send")
+	})
+}
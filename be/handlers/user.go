@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"be-test/apperror"
+	"be-test/database"
+	"be-test/helpers"
+	"be-test/i18n"
+	"be-test/models"
+	"be-test/repository"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type roleInput struct {
+	Role models.Role `json:"role" binding:"required"`
+}
+
+type localeInput struct {
+	Locale string `json:"locale"`
+}
+
+func (h *Handler) GetUsers(c *gin.Context) {
+	page, limit, opts := pagination(c)
+
+	users, totalItems, err := h.Users.List(c.Request.Context(), opts)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, gin.H{
+		"page":        page,
+		"limit":       limit,
+		"total_items": totalItems,
+		"total_pages": (totalItems + int64(limit) - 1) / int64(limit),
+		"users":       users,
+	}, nil, "", 0, "user.retrieved")
+}
+
+// InviteUser invites an email into the caller's organization and sends it a magic link
+func (h *Handler) InviteUser(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input models.Invitation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	if !input.Role.Valid() {
+		c.Error(apperror.RoleUnknown)
+		return
+	}
+
+	// A user belongs to exactly one organization
+	_, err := h.Users.FindByEmail(c.Request.Context(), input.Email)
+	if err == nil {
+		c.Error(apperror.UserExists)
+		return
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		c.Error(err)
+		return
+	}
+
+	// Inviting the same email again refreshes the pending invitation. Signing
+	// in accepts whichever invitation is pending, so only one organization at
+	// a time may have one for an email.
+	invitation, err := findPendingInvitation(database.WithoutTenant(db), input.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(err)
+		return
+	}
+	if err == nil && invitation.OrganizationID != c.GetUint("organization_id") {
+		c.Error(apperror.InvitationPending)
+		return
+	}
+	invitation.Email = input.Email
+	invitation.Role = input.Role
+	invitation.InvitedByID = c.GetUint("user_id")
+	invitation.ExpiresAt = time.Now().Add(time.Hour * 24 * 7)
+
+	if err := db.Save(&invitation).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.sendMagicLink(c, database.WithoutTenant(db), invitation.OrganizationID, invitation.Email); err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, gin.H{"invitation": invitation}, nil, "", 0, "user.invited")
+}
+
+// UpdateUserRole changes the role of a user in the caller's organization
+func (h *Handler) UpdateUserRole(c *gin.Context) {
+	var input roleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	if !input.Role.Valid() {
+		c.Error(apperror.RoleUnknown)
+		return
+	}
+
+	user, ok := h.findManagedUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Users.UpdateRole(c.Request.Context(), &user, input.Role); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Sessions were issued for the old role
+	if err := h.revokeUserSessions(c, user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	helpers.NewAPIResponse(c, gin.H{"user": user}, nil, "", 0, "user.role_updated")
+}
+
+// DeactivateUser blocks a user from signing in and from using existing tokens
+func (h *Handler) DeactivateUser(c *gin.Context) {
+	h.setUserActive(c, false)
+}
+
+// ActivateUser lifts a previous deactivation
+func (h *Handler) ActivateUser(c *gin.Context) {
+	h.setUserActive(c, true)
+}
+
+func (h *Handler) setUserActive(c *gin.Context, active bool) {
+	user, ok := h.findManagedUser(c)
+	if !ok {
+		return
+	}
+
+	var deactivatedAt *time.Time
+	message := "user.activated"
+	if !active {
+		now := time.Now()
+		deactivatedAt = &now
+		message = "user.deactivated"
+	}
+
+	if err := h.Users.SetDeactivatedAt(c.Request.Context(), &user, deactivatedAt); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if !active {
+		if err := h.revokeUserSessions(c, user); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	helpers.NewAPIResponse(c, gin.H{"user": user}, nil, "", 0, message)
+}
+
+// UpdateLocale sets the language the caller's API messages are sent in. An
+// empty locale goes back to following the Accept-Language header.
+func (h *Handler) UpdateLocale(c *gin.Context) {
+	var input localeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	if input.Locale != "" && !i18n.Supported(input.Locale) {
+		c.Error(apperror.LocaleUnsupported)
+		return
+	}
+
+	user, err := h.Users.Get(c.Request.Context(), c.GetUint("user_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.Users.UpdateLocale(c.Request.Context(), &user, input.Locale); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Answer in the new language already
+	c.Set("locale", input.Locale)
+	helpers.NewAPIResponse(c, gin.H{"user": user}, nil, "", 0, "user.locale_updated")
+}
+
+// findManagedUser loads the user in the path, refusing changes to the caller's
+// own account so an owner cannot lock the organization out
+func (h *Handler) findManagedUser(c *gin.Context) (models.User, bool) {
+	user, err := h.Users.Get(c.Request.Context(), pathID(c))
+	if err != nil {
+		c.Error(apperror.WhenNotFound(err, apperror.UserNotFound))
+		return user, false
+	}
+
+	if user.ID == c.GetUint("user_id") {
+		c.Error(apperror.CannotChangeSelf)
+		return user, false
+	}
+
+	return user, true
+}
+
+// revokeUserSessions signs user out everywhere, after a change that their
+// existing tokens must not outlive
+func (h *Handler) revokeUserSessions(c *gin.Context, user models.User) error {
+	return revokeSessions(database.WithoutTenant(h.DB.WithContext(c.Request.Context())).Where("user_id = ?", user.ID))
+}
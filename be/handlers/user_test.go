@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserEndpoints(t *testing.T) {
+	r := setupTestRouter()
+
+	ownerToken := loginAs(t, r, "owner@example.com")
+	var baristaToken string
+	var baristaID float64
+
+	t.Run("Invite User", func(t *testing.T) {
+		jsonData, _ := json.Marshal(map[string]string{"email": "barista@example.com", "role": "barista"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/users/invitations", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		baristaToken = loginAs(t, r, "barista@example.com")
+	})
+
+	t.Run("Invite Existing User", func(t *testing.T) {
+		jsonData, _ := json.Marshal(map[string]string{"email": "barista@example.com", "role": "viewer"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/users/invitations", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 409, w.Code)
+	})
+
+	t.Run("Invite Email Invited By Another Organization", func(t *testing.T) {
+		rivalToken := loginAs(t, r, "rival-owner@example.com")
+		invite := func(token string) *httptest.ResponseRecorder {
+			jsonData, _ := json.Marshal(map[string]string{"email": "contested@example.com", "role": "barista"})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/users/invitations", bytes.NewBuffer(jsonData))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", token)
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.Equal(t, 200, invite(rivalToken).Code)
+		w := invite(ownerToken)
+		assert.Equal(t, 409, w.Code)
+		assert.Equal(t, "INVITATION_PENDING", errorCode(w))
+		// The inviting organization can still refresh its own invitation
+		assert.Equal(t, 200, invite(rivalToken).Code)
+	})
+
+	t.Run("Get Users", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/users?search=barista@example.com", nil)
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		data := response["data"].(map[string]interface{})
+		users := data["users"].([]interface{})
+		assert.Len(t, users, 1)
+		baristaID = users[0].(map[string]interface{})["ID"].(float64)
+	})
+
+	t.Run("Get Users As Barista", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/users", nil)
+		req.Header.Set("Authorization", baristaToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 403, w.Code)
+	})
+
+	t.Run("Deactivate User", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/users/%d/deactivate", int(baristaID)), nil)
+		req.Header.Set("Authorization", ownerToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/inventory", nil)
+		req.Header.Set("Authorization", baristaToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, 401, w.Code)
+	})
+}
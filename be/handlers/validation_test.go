@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// errorFields returns the field errors of an API response
+func errorFields(w *httptest.ResponseRecorder) map[string]string {
+	var response struct {
+		Error struct {
+			Fields map[string]string `json:"fields"`
+		} `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Error.Fields
+}
+
+func TestInputValidation(t *testing.T) {
+	r := setupTestRouter()
+	token := loginAs(t, r, "validation@example.com")
+
+	w := serve(r, "POST", "/inventory", `{"item_name": "Validation Beans", "quantity": 1, "uom": "kg", "price_per_qty": 200000}`, "Authorization", token, "Accept-Language", "en")
+	assert.Equal(t, 200, w.Code)
+
+	inventoryCases := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"Blank Name", `{"item_name": "  ", "quantity": 1, "uom": "kg", "price_per_qty": 1}`, "item_name"},
+		{"Zero Quantity", `{"item_name": "Beans", "quantity": 0, "uom": "kg", "price_per_qty": 1}`, "quantity"},
+		{"Negative Price", `{"item_name": "Beans", "quantity": 1, "uom": "kg", "price_per_qty": -5}`, "price_per_qty"},
+		{"Unknown Unit", `{"item_name": "Beans", "quantity": 1, "uom": "tbsp", "price_per_qty": 1}`, "uom"},
+		{"Negative Reorder Level", `{"item_name": "Beans", "quantity": 1, "uom": "kg", "price_per_qty": 1, "reorder_level": -1}`, "reorder_level"},
+	}
+	for _, tc := range inventoryCases {
+		t.Run("Inventory "+tc.name, func(t *testing.T) {
+			w := serve(r, "POST", "/inventory", tc.body, "Authorization", token, "Accept-Language", "en")
+			assert.Equal(t, 400, w.Code)
+			assert.Equal(t, "VALIDATION_FAILED", errorCode(w))
+			assert.Contains(t, errorFields(w), tc.field)
+		})
+	}
+
+	recipeCases := []struct {
+		name  string
+		body  string
+		field string
+		want  string
+	}{
+		{"Zero Cups", `{"number_of_cups": 0, "ingredients": {"Validation Beans": {"amount": 18, "unit": "g"}}}`, "number_of_cups", ""},
+		{"No Ingredients", `{"number_of_cups": 1, "ingredients": {}}`, "ingredients", ""},
+		{"Unknown Ingredient", `{"number_of_cups": 1, "ingredients": {"Oat Milk": {"amount": 150, "unit": "ml"}}}`, "ingredients[Oat Milk]", "is not in the inventory"},
+		{"Zero Amount", `{"number_of_cups": 1, "ingredients": {"Validation Beans": {"amount": 0, "unit": "g"}}}`, "ingredients[Validation Beans].amount", "must be greater than 0"},
+		{"Unknown Unit", `{"number_of_cups": 1, "ingredients": {"Validation Beans": {"amount": 1, "unit": "tbsp"}}}`, "ingredients[Validation Beans].unit", ""},
+	}
+	for _, tc := range recipeCases {
+		t.Run("Recipe "+tc.name, func(t *testing.T) {
+			w := serve(r, "POST", "/recipe", tc.body, "Authorization", token, "Accept-Language", "en")
+			assert.Equal(t, 400, w.Code)
+			assert.Equal(t, "VALIDATION_FAILED", errorCode(w))
+			fields := errorFields(w)
+			assert.Contains(t, fields, tc.field)
+			if tc.want != "" {
+				assert.Equal(t, tc.want, fields[tc.field])
+			}
+		})
+	}
+
+	t.Run("Valid Recipe", func(t *testing.T) {
+		w := serve(r, "POST", "/recipe", `{"number_of_cups": 1, "ingredients": {"Validation Beans": {"amount": 18, "unit": "g"}}}`, "Authorization", token, "Accept-Language", "en")
+		assert.Equal(t, 200, w.Code)
+	})
+}
@@ -2,38 +2,48 @@ package helpers
 
 import (
 	"errors"
-	"os"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
-// GenerateJWT generates a JWT token for a user's email
-func GenerateJWT(email string, exp int64) (string, error) {
+// GenerateAccessToken generates an access token carrying the user's email, role and session
+func GenerateAccessToken(email string, role string, sessionID uint, exp int64) (string, error) {
 	claims := jwt.MapClaims{
 		"email": email,
+		"role":  role,
+		"sid":   sessionID,
 		"exp":   exp,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	key, err := signingKeys.current()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.key)
 }
 
+// ErrTokenExpired is returned by ValidateJWT for a token past its expiry
+var ErrTokenExpired = errors.New("token is expired")
+
 // ValidateJWT validates the JWT token and returns the claims
 func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Check if the signing method is HMAC
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.NewValidationError("invalid signing method", jwt.ValidationErrorSignatureInvalid)
+		// Pick the verification key the token was signed with
+		kid, _ := token.Header["kid"].(string)
+		key, ok := signingKeys.lookup(kid)
+		if !ok {
+			return nil, errors.New("unknown signing key")
 		}
-		return jwtSecret, nil
-	})
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithExpirationRequired())
 
 	if err != nil {
 		// If the error is that the token is expired, we return a specific error message
-		if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors == jwt.ValidationErrorExpired {
-			return nil, errors.New("token is expired")
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
 		}
 		return nil, err
 	}
@@ -0,0 +1,319 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kidFormat names keys after their creation time so the newest sorts last
+const kidFormat = "20060102T150405Z"
+
+const rsaKeyBits = 2048
+
+// publishLead is how long a new key is published, in the key directory and
+// the JWKS, before it starts signing. It outlasts the JWKS cache lifetime and
+// the minute between reloads, so every verifier knows a key before it sees a
+// token signed by it.
+const publishLead = 10 * time.Minute
+
+// reloadGap rate-limits the reloads caused by tokens signed with an unknown key
+const reloadGap = 5 * time.Second
+
+var errNoSigningKey = errors.New("no signing key loaded")
+
+type signingKey struct {
+	kid       string
+	createdAt time.Time
+	key       *rsa.PrivateKey
+}
+
+// KeySet holds the RSA keys used to sign and verify access tokens. The newest
+// key published for at least publishLead signs; every loaded key verifies, so
+// tokens survive a rotation.
+type KeySet struct {
+	mu         sync.RWMutex
+	dir        string
+	keys       map[string]signingKey
+	reloadedAt time.Time
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+var signingKeys = &KeySet{keys: map[string]signingKey{}}
+
+// LoadSigningKeys loads every <kid>.pem private key from dir, generating the
+// first key when the directory is empty. An empty dir keeps a single key in
+// memory, which is only suitable for local runs and tests.
+func LoadSigningKeys(dir string) error {
+	signingKeys.mu.Lock()
+	signingKeys.dir = dir
+	signingKeys.mu.Unlock()
+
+	if dir == "" {
+		slog.Warn("JWT_KEY_DIR is not set, using an in-memory signing key")
+		return signingKeys.Rotate()
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := signingKeys.Reload(); err != nil {
+		return err
+	}
+	if _, err := signingKeys.current(); errors.Is(err, errNoSigningKey) {
+		return signingKeys.Rotate()
+	}
+	return nil
+}
+
+// RunKeyRotation generates a new key publishLead before the newest one is
+// interval old, so it starts signing at that age, and drops keys older than
+// interval plus retention. Retention must outlive the access tokens signed by
+// a retired key. The directory is reloaded every minute to pick up keys
+// written by other instances sharing it. It returns once ctx is cancelled.
+func RunKeyRotation(ctx context.Context, interval time.Duration, retention time.Duration) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := signingKeys.rotateIfDue(interval, retention); err != nil {
+				slog.Error("signing key rotation failed", "error", err.Error())
+			}
+		}
+	}
+}
+
+// JWKS returns the public halves of all verification keys
+func JWKS() []JWK {
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+
+	jwks := make([]JWK, 0, len(signingKeys.keys))
+	for _, k := range signingKeys.sorted() {
+		jwks = append(jwks, JWK{
+			Kty: "RSA",
+			Kid: k.kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+		})
+	}
+	return jwks
+}
+
+// Reload reads the key directory again
+func (s *KeySet) Reload() error {
+	s.mu.RLock()
+	dir := s.dir
+	s.mu.RUnlock()
+	if dir == "" {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]signingKey{}
+	for _, file := range files {
+		k, err := readSigningKey(file)
+		if err != nil {
+			return err
+		}
+		keys[k.kid] = k
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.reloadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// Rotate generates a new key and makes it the signing key
+func (s *KeySet) Rotate() error {
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	k := signingKey{kid: now.Format(kidFormat), createdAt: now, key: key}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir != "" {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return err
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(filepath.Join(s.dir, k.kid+".pem"), data, 0600); err != nil {
+			return err
+		}
+	}
+	s.keys[k.kid] = k
+	return nil
+}
+
+func (s *KeySet) rotateIfDue(interval time.Duration, retention time.Duration) error {
+	if err := s.Reload(); err != nil {
+		return err
+	}
+
+	newest, err := s.newest()
+	if err != nil && !errors.Is(err, errNoSigningKey) {
+		return err
+	}
+	if err == nil && time.Since(newest.createdAt) < interval-publishLead {
+		return nil
+	}
+	if err := s.Rotate(); err != nil {
+		return err
+	}
+
+	// Retire keys that can no longer have valid tokens in circulation
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for kid, k := range s.keys {
+		if time.Since(k.createdAt) <= interval+retention {
+			continue
+		}
+		if s.dir != "" {
+			if err := os.Remove(filepath.Join(s.dir, kid+".pem")); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		delete(s.keys, kid)
+	}
+	return nil
+}
+
+// current returns the signing key: the newest key published for at least
+// publishLead, or the oldest key when all are newer, as in a new key directory
+func (s *KeySet) current() (signingKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sorted := s.sorted()
+	if len(sorted) == 0 {
+		return signingKey{}, errNoSigningKey
+	}
+	for i := len(sorted) - 1; i > 0; i-- {
+		if time.Since(sorted[i].createdAt) >= publishLead {
+			return sorted[i], nil
+		}
+	}
+	return sorted[0], nil
+}
+
+// newest returns the most recently generated key, which may not sign yet
+func (s *KeySet) newest() (signingKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sorted := s.sorted()
+	if len(sorted) == 0 {
+		return signingKey{}, errNoSigningKey
+	}
+	return sorted[len(sorted)-1], nil
+}
+
+// lookup returns the public key named kid. An unknown kid may have been
+// written by another instance since the last reload, so the directory is read
+// again first, at most once every reloadGap.
+func (s *KeySet) lookup(kid string) (*rsa.PublicKey, bool) {
+	if key, ok := s.find(kid); ok {
+		return key, true
+	}
+
+	s.mu.Lock()
+	due := s.dir != "" && time.Since(s.reloadedAt) >= reloadGap
+	if due {
+		s.reloadedAt = time.Now()
+	}
+	s.mu.Unlock()
+	if !due {
+		return nil, false
+	}
+	if err := s.Reload(); err != nil {
+		slog.Error("reloading signing keys failed", "error", err.Error())
+		return nil, false
+	}
+	return s.find(kid)
+}
+
+func (s *KeySet) find(kid string) (*rsa.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.keys[kid]
+	if !ok {
+		return nil, false
+	}
+	return &k.key.PublicKey, true
+}
+
+// sorted returns the keys oldest first. The caller must hold the lock.
+func (s *KeySet) sorted() []signingKey {
+	keys := make([]signingKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].kid < keys[j].kid })
+	return keys
+}
+
+func readSigningKey(file string) (signingKey, error) {
+	kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+	createdAt, err := time.Parse(kidFormat, kid)
+	if err != nil {
+		return signingKey{}, errors.New("key file name is not a key ID: " + file)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return signingKey{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return signingKey{}, errors.New("key file is not PEM encoded: " + file)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return signingKey{}, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return signingKey{}, errors.New("key file is not an RSA key: " + file)
+	}
+
+	return signingKey{kid: kid, createdAt: createdAt, key: key}, nil
+}
@@ -0,0 +1,91 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeKey writes a key created at createdAt to dir, as Rotate would have, and returns its kid
+func writeKey(t *testing.T, dir string, createdAt time.Time) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := createdAt.UTC().Format(kidFormat)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return kid
+}
+
+func TestKeySetsSharingADirectory(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	signing := writeKey(t, dir, now.Add(-90*time.Minute))
+
+	a := &KeySet{dir: dir, keys: map[string]signingKey{}}
+	b := &KeySet{dir: dir, keys: map[string]signingKey{}}
+	for _, s := range []*KeySet{a, b} {
+		if err := s.Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("A New Key Is Published Before It Signs", func(t *testing.T) {
+		if err := a.rotateIfDue(time.Hour, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		newest, err := a.newest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotEqual(t, signing, newest.kid)
+
+		current, err := a.current()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, signing, current.kid)
+	})
+
+	t.Run("An Unknown Key Is Looked Up On Disk", func(t *testing.T) {
+		kid := writeKey(t, dir, now.Add(-publishLead))
+
+		b.mu.Lock()
+		b.reloadedAt = time.Time{}
+		b.mu.Unlock()
+		_, ok := b.lookup(kid)
+		assert.True(t, ok)
+
+		// Within reloadGap a miss does not read the directory again, even for a key written since
+		missing := writeKey(t, dir, now.Add(-time.Minute))
+		_, ok = b.lookup(missing)
+		assert.False(t, ok)
+	})
+
+	t.Run("The Newest Published Key Signs", func(t *testing.T) {
+		if err := a.Reload(); err != nil {
+			t.Fatal(err)
+		}
+		current, err := a.current()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, now.Add(-publishLead).UTC().Format(kidFormat), current.kid)
+	})
+}
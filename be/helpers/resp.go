@@ -1,15 +1,17 @@
 package helpers
 
 import (
+	"be-test/apperror"
+	"be-test/i18n"
+	"be-test/logging"
 	"errors"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"reflect"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"gorm.io/gorm"
 )
 
 type MessageType struct {
@@ -19,47 +21,112 @@ type MessageType struct {
 }
 
 type APIResponseNew struct {
-	Message MessageType       `json:"message"`
-	Data    interface{}       `json:"data"`
-	Error   map[string]string `json:"error"`
+	Message MessageType `json:"message"`
+	Data    interface{} `json:"data"`
+	Error   *APIError   `json:"error"`
+	// RequestID is set on errors so a report can be matched to the server logs
+	RequestID string `json:"request_id,omitempty"`
 }
 
-func getStatusFromError(err error) int {
-	switch {
-	case errors.Is(err, gorm.ErrRecordNotFound):
-		return http.StatusNotFound
-	case errors.As(err, &validator.ValidationErrors{}):
-		return http.StatusBadRequest
-	case strings.Contains(err.Error(), "Bind"):
-		return http.StatusBadRequest
-	default:
-		return http.StatusInternalServerError
+// APIError is the error of a failed request
+type APIError struct {
+	// Code is one of the codes in the apperror package, e.g. INVENTORY_NOT_FOUND
+	Code string `json:"code"`
+	// Fields maps invalid request fields to what is wrong with them
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Locale returns the locale to answer in: the signed-in user's preference,
+// or else the best match for the Accept-Language header
+func Locale(c *gin.Context) string {
+	if locale := c.GetString("locale"); i18n.Supported(locale) {
+		return locale
 	}
+	return i18n.Match(c.GetHeader("Accept-Language"))
 }
 
-func formatValidationError(err error) map[string]string {
-	errors := make(map[string]string)
+// formatValidationError describes each failed binding rule in locale, keyed by field
+func formatValidationError(err error, locale string) map[string]string {
+	fields := make(map[string]string)
 
-	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
 		for _, e := range validationErrors {
-			field := strings.ToLower(e.Field())
-			errors[field] = fmt.Sprintf("Field validation for '%s' failed on the '%s'", field, e.Tag())
+			// Name nested fields by their path below the bound struct, e.g. ingredients[Milk].unit
+			_, field, ok := strings.Cut(e.Namespace(), ".")
+			if !ok {
+				field = e.Field()
+			}
+			switch id := "validation." + e.Tag(); {
+			case !i18n.Has(i18n.DefaultLocale, id):
+				fields[field] = i18n.T(locale, "validation.default", e.Tag())
+			case e.Param() != "":
+				fields[field] = i18n.T(locale, id, e.Param())
+			default:
+				fields[field] = i18n.T(locale, id)
+			}
 		}
-		return errors
+		return fields
 	}
 
 	return nil
 }
 
+// NewErrorResponse responds with err. The cause of a server error is logged
+// with the request ID, and never sent to the client.
+func NewErrorResponse(c *gin.Context, err *apperror.Error) {
+	ctx := c.Request.Context()
+	if err.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, "request failed", "status", err.Status, "code", err.Code, "error", err.Cause)
+	}
+
+	// Field details are message IDs or, like a missing permission, plain values
+	locale := Locale(c)
+	fields := formatValidationError(err.Cause, locale)
+	for field, detail := range err.Fields {
+		if fields == nil {
+			fields = make(map[string]string, len(err.Fields))
+		}
+		fields[field] = i18n.T(locale, detail)
+	}
+
+	text := err.Message
+	if id := "error." + err.Code; i18n.Has(i18n.DefaultLocale, id) {
+		text = i18n.T(locale, id)
+	}
+
+	msg := MessageType{}
+	switch {
+	case err.Status >= http.StatusInternalServerError,
+		err.Status == http.StatusUnauthorized,
+		err.Status == http.StatusForbidden,
+		err.Status == http.StatusNotAcceptable:
+		msg.Danger = text
+	default:
+		msg.Warning = text
+	}
+
+	c.JSON(err.Status, APIResponseNew{
+		Message:   msg,
+		Data:      err.Data,
+		Error:     &APIError{Code: err.Code, Fields: fields},
+		RequestID: logging.RequestID(ctx),
+	})
+}
+
+// NewAPIResponse responds with data. A non-nil err is rendered by NewErrorResponse
+// instead; handlers should rather report errors with c.Error.
 func NewAPIResponse(c *gin.Context, data interface{}, err error, attribute_or_entity string, status_code int, message string) {
+	if err != nil {
+		NewErrorResponse(c, apperror.From(err))
+		return
+	}
+
 	response := APIResponseNew{}
 	default_attribute_or_entity := attribute_or_entity
 	method := c.Request.Method
 	if status_code == 0 {
 		status_code = http.StatusOK
-		if err != nil {
-			status_code = getStatusFromError(err)
-		}
 	}
 
 	// Get the type name and check if it's a slice
@@ -99,100 +166,61 @@ func NewAPIResponse(c *gin.Context, data interface{}, err error, attribute_or_en
 		}
 	}
 
-	if err == nil {
-		if struct_name != "h" {
-			attribute_or_entity = struct_name
-			if attribute_or_entity != "" {
-				struct_name = attribute_or_entity
-			}
-		}
-	}
-	if err != nil {
-		if struct_name != "" {
-			attribute_or_entity = struct_name
-		}
-		if validationErrors := formatValidationError(err); validationErrors != nil {
-			response.Error = validationErrors
-		} else {
-			response.Error = map[string]string{
-				attribute_or_entity: err.Error(),
-			}
+	if struct_name != "h" {
+		attribute_or_entity = struct_name
+		if attribute_or_entity != "" {
+			struct_name = attribute_or_entity
 		}
 	}
 	if default_attribute_or_entity != "" {
 		attribute_or_entity = default_attribute_or_entity
 	}
 
+	locale := Locale(c)
+	text := func(defaultID string, args ...any) string {
+		if message != "" {
+			return i18n.T(locale, message)
+		}
+		return i18n.T(locale, defaultID, args...)
+	}
+
 	msg := MessageType{}
 	switch status_code {
 	case http.StatusOK:
 		switch method {
 		case "GET":
-			if message == "" {
-				msg.Success = fmt.Sprintf("Berhasil mengambil data %s", attribute_or_entity)
-			} else {
-				msg.Success = message
-			}
+			msg.Success = text("response.retrieved", attribute_or_entity)
 		case "POST":
-			if message == "" {
-				msg.Success = fmt.Sprintf("Berhasil menambah data %s", attribute_or_entity)
-			} else {
-				msg.Success = message
-			}
-		case "PUT":
-			if message == "" {
-				msg.Success = fmt.Sprintf("Berhasil mengubah data %s", attribute_or_entity)
-			} else {
-				msg.Success = message
-			}
+			msg.Success = text("response.added", attribute_or_entity)
+		case "PUT", "PATCH":
+			msg.Success = text("response.updated", attribute_or_entity)
 		case "DELETE":
-			if message == "" {
-				msg.Success = fmt.Sprintf("Berhasil menghapus data %s", attribute_or_entity)
-			} else {
-				msg.Success = message
-			}
+			msg.Success = text("response.deleted", attribute_or_entity)
 		}
 	case http.StatusNoContent:
-		msg.Success = fmt.Sprintf("Data %s belum tersedia", attribute_or_entity)
+		msg.Success = i18n.T(locale, "response.empty", attribute_or_entity)
 	case http.StatusBadRequest:
-		if message == "" {
-			msg.Warning = "Permintaan tidak valid"
-		} else {
-			msg.Warning = message
-		}
+		msg.Warning = text("response.bad_request")
 	case http.StatusUnauthorized:
-		if message == "" {
-			msg.Danger = "Tidak memiliki akses"
-		} else {
-			msg.Danger = message
-		}
+		msg.Danger = text("response.unauthorized")
 	case http.StatusNotFound:
-		if message == "" {
-			msg.Warning = fmt.Sprintf("Data %s tidak ditemukan", attribute_or_entity)
-		} else {
-			msg.Warning = message
-		}
-	case http.StatusPreconditionRequired:
-		msg.Warning = message
-	case http.StatusServiceUnavailable:
-		msg.Danger = message
-	case http.StatusNotAcceptable:
-		msg.Danger = message
-	case http.StatusForbidden:
-		msg.Danger = message
+		msg.Warning = text("response.not_found", attribute_or_entity)
+	case http.StatusConflict, http.StatusTooManyRequests, http.StatusPreconditionRequired:
+		msg.Warning = i18n.T(locale, message)
+	case http.StatusServiceUnavailable, http.StatusNotAcceptable, http.StatusForbidden:
+		msg.Danger = i18n.T(locale, message)
 	case http.StatusBadGateway:
-		msg.Danger = "Server tidak dapat merespons permintaan, mohon tunggu beberapa saat"
+		msg.Danger = i18n.T(locale, "response.bad_gateway")
 	case http.StatusInternalServerError:
-		if message == "" {
-			msg.Danger = "Terjadi kesalahan pada server"
-		} else {
-			msg.Danger = message
-		}
+		msg.Danger = text("response.internal")
 	}
 	response.Message = msg
 
-	if data != nil && err == nil {
-		fmt.Println("ss")
+	if status_code >= http.StatusBadRequest {
+		response.RequestID = logging.RequestID(c.Request.Context())
+	}
+
+	if data != nil {
 		// Wrap the data in a map with plural struct name as key
 		v := reflect.ValueOf(data)
 		if v.Kind() == reflect.Slice && v.Len() == 0 {
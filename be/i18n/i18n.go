@@ -0,0 +1,101 @@
+// Package i18n translates API messages. Messages are looked up by ID in the
+// catalogs in locales/<locale>.json, which must define the same IDs.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"golang.org/x/text/language"
+)
+
+//go:embed locales
+var localeFS embed.FS
+
+// DefaultLocale is used when the caller asks for no supported locale
+const DefaultLocale = "id"
+
+// supportedLocales lists the locales with a catalog, the default first
+var supportedLocales = []language.Tag{language.Indonesian, language.English}
+
+var localeMatcher = language.NewMatcher(supportedLocales)
+
+// catalogs maps each locale to its messages by ID
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	catalogs := make(map[string]map[string]string, len(supportedLocales))
+	for _, tag := range supportedLocales {
+		base, _ := tag.Base()
+		data, err := localeFS.ReadFile("locales/" + base.String() + ".json")
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: locales/%s.json: %v", base, err))
+		}
+		catalogs[base.String()] = messages
+	}
+	return catalogs
+}
+
+// Match picks the supported locale that best fits an Accept-Language header
+func Match(acceptLanguage string) string {
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	_, index, _ := localeMatcher.Match(tags...)
+	base, _ := supportedLocales[index].Base()
+	return base.String()
+}
+
+// Supported reports whether locale has a catalog
+func Supported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// Locales returns the supported locales
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for _, tag := range supportedLocales {
+		base, _ := tag.Base()
+		locales = append(locales, base.String())
+	}
+	return locales
+}
+
+// T returns message id in locale, formatted with args. It falls back to
+// DefaultLocale, and returns id itself when no catalog defines it, so text
+// that is not a message ID passes through unchanged.
+func T(locale string, id string, args ...any) string {
+	message, ok := catalogs[locale][id]
+	if !ok {
+		message, ok = catalogs[DefaultLocale][id]
+	}
+	if !ok {
+		message = id
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Has reports whether the catalog of locale defines id
+func Has(locale string, id string) bool {
+	_, ok := catalogs[locale][id]
+	return ok
+}
+
+// IDs returns the message IDs defined for locale, sorted
+func IDs(locale string) []string {
+	ids := make([]string, 0, len(catalogs[locale]))
+	for id := range catalogs[locale] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,121 @@
+// Package logging sets up the structured JSON logger. Records logged with a
+// request context carry its request ID and signed-in user, and attributes
+// named like credentials are redacted.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the value of secret attributes
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute and query parameter names whose values are never logged
+var secretKeys = map[string]bool{
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"authorization": true,
+	"cookie":        true,
+	"api_key":       true,
+	"x-api-key":     true,
+}
+
+// IsSecret reports whether values named key must not be logged
+func IsSecret(key string) bool {
+	key = strings.ToLower(key)
+	return secretKeys[key] || strings.HasSuffix(key, "_password") || strings.HasSuffix(key, "_token")
+}
+
+// New returns a JSON logger writing records at level and above to w
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	})
+	return slog.New(contextHandler{handler})
+}
+
+// redact hides the values of secret attributes and of anything that looks
+// like an Authorization header
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if IsSecret(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	if a.Value.Kind() == slog.KindString && strings.HasPrefix(a.Value.String(), "Bearer ") {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// RedactQuery returns the query string with the values of secret parameters hidden
+func RedactQuery(query url.Values) string {
+	redacted := url.Values{}
+	for key, values := range query {
+		if IsSecret(key) {
+			redacted[key] = []string{Redacted}
+			continue
+		}
+		redacted[key] = values
+	}
+	return redacted.Encode()
+}
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	userKey
+)
+
+// WithRequestID returns ctx carrying the ID of the request it serves
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "" when there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithUser returns ctx carrying the email of the signed-in user
+func WithUser(ctx context.Context, email string) context.Context {
+	return context.WithValue(ctx, userKey, email)
+}
+
+// contextHandler adds the request ID, user and trace found in the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if user, _ := ctx.Value(userKey).(string); user != "" {
+			r.AddAttrs(slog.String("user", user))
+		}
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			r.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
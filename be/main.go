@@ -1,23 +1,145 @@
 package main
 
 import (
+	"be-test/config"
 	"be-test/database"
+	handler "be-test/handlers"
+	"be-test/helpers"
+	"be-test/logging"
+	"be-test/metrics"
+	"be-test/middleware"
 	"be-test/route"
+	"be-test/tracing"
+	"be-test/utils"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
-	// Initialize database
-	database.Init()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal("Invalid configuration:\n", err)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrate(cfg, args[1:])
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration:\n", err)
+	}
+	slog.SetDefault(logging.New(os.Stdout, cfg.LogLevel))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+
+	// Initialize database, refusing to start on an unmigrated schema
+	db := database.Init(cfg.Database)
+	mailer := utils.NewMailer(cfg.Mail)
+	prometheus.MustRegister(metrics.NewBusinessCollector(database.WithoutTenant(db)))
+
+	// Load the token signing keys
+	if err := helpers.LoadSigningKeys(cfg.JWT.KeyDir); err != nil {
+		fatal("failed to load signing keys", err)
+	}
+
+	// Background workers run until shutdown begins
+	workers, stopWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	// Deliver queued emails
+	go func() {
+		defer wg.Done()
+		utils.NewEmailWorker(db, mailer).Run(workers)
+	}()
+	// Retired keys stay published for an hour, well past the 15 minute access token lifetime
+	go func() {
+		defer wg.Done()
+		helpers.RunKeyRotation(workers, cfg.JWT.KeyRotation, time.Hour)
+	}()
 
 	// Set up Gin router
-	router := gin.Default()
+	router := gin.New()
 
 	// Set up routes
-	route.SetupRoutes(router)
+	route.SetupRoutes(router, handler.New(cfg, db, mailer), middleware.AuthMiddleware(db))
+
+	slog.Info("listening", "port", cfg.Port)
+	server := &http.Server{
+		Addr:         ":" + strconv.Itoa(cfg.Port),
+		Handler:      router,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
+
+	// Run the server until SIGINT or SIGTERM
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		fatal("server failed", err)
+	case <-signals.Done():
+	}
+	stop()
+	slog.Info("shutting down, draining requests and background workers")
+
+	// Stop accepting connections and wait for in-flight requests, then for
+	// the workers, all within the shutdown timeout
+	shutdown, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdown); err != nil {
+		slog.Warn("requests did not finish in time", "error", err.Error())
+	}
+
+	stopWorkers()
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-shutdown.Done():
+		slog.Warn("background workers did not finish in time")
+	}
+
+	if err := shutdownTracing(shutdown); err != nil {
+		slog.Warn("pending spans were not exported", "error", err.Error())
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	slog.Info("server stopped")
+}
 
-	// Run the server
-	router.Run(":" + os.Getenv("PORT"))
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err.Error())
+	os.Exit(1)
 }
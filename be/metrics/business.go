@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"be-test/models"
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+var (
+	lowStockDesc = prometheus.NewDesc(
+		"inventory_low_stock_items",
+		"Inventory items at or below their reorder level, across all organizations.",
+		nil, nil,
+	)
+	staleCOGSDesc = prometheus.NewDesc(
+		"recipes_stale_cogs",
+		"Recipes whose COGS was calculated before one of their ingredients changed or was deleted.",
+		nil, nil,
+	)
+)
+
+// collectTimeout bounds the queries run for one scrape
+const collectTimeout = 5 * time.Second
+
+// BusinessCollector reports inventory and recipe health, read from the
+// database on every scrape
+type BusinessCollector struct {
+	db *gorm.DB
+}
+
+// NewBusinessCollector returns a collector reading from db, which must not be
+// scoped to a tenant since the gauges cover every organization
+func NewBusinessCollector(db *gorm.DB) *BusinessCollector {
+	return &BusinessCollector{db: db}
+}
+
+// Describe implements prometheus.Collector
+func (b *BusinessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lowStockDesc
+	ch <- staleCOGSDesc
+}
+
+// Collect implements prometheus.Collector
+func (b *BusinessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+	db := b.db.WithContext(ctx)
+
+	var lowStock int64
+	err := db.Model(&models.Inventory{}).
+		Where("reorder_level > 0 AND quantity <= reorder_level").
+		Count(&lowStock).Error
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(lowStockDesc, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(lowStockDesc, prometheus.GaugeValue, float64(lowStock))
+	}
+
+	stale, err := staleRecipes(db)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(staleCOGSDesc, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(staleCOGSDesc, prometheus.GaugeValue, float64(stale))
+	}
+}
+
+// ingredientNames lists the ingredient names of a recipe row as a table with a
+// name column, in the JSON functions of each dialect
+var ingredientNames = map[string]string{
+	"postgres": "jsonb_object_keys(recipes.ingredients) AS ingredient(name)",
+	"sqlite":   "(SELECT key AS name FROM json_each(recipes.ingredients)) AS ingredient",
+}
+
+// staleRecipes counts recipes with an ingredient that was updated after the
+// recipe, or that is no longer in the inventory of its organization. The
+// database does the counting so a scrape does not load every recipe.
+func staleRecipes(db *gorm.DB) (int64, error) {
+	var stale int64
+	err := db.Raw(`SELECT COUNT(*) FROM recipes
+WHERE recipes.deleted_at IS NULL AND EXISTS (
+    SELECT 1 FROM ` + ingredientNames[db.Dialector.Name()] + `
+    WHERE NOT EXISTS (
+        SELECT 1 FROM inventories
+        WHERE inventories.organization_id = recipes.organization_id
+          AND LOWER(inventories.item_name) = LOWER(ingredient.name)
+          AND inventories.deleted_at IS NULL
+          AND inventories.updated_at <= recipes.updated_at
+    )
+)`).Scan(&stale).Error
+	return stale, err
+}
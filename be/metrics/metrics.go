@@ -0,0 +1,59 @@
+// Package metrics defines the Prometheus metrics of the server: HTTP traffic,
+// database query timings, email deliveries and inventory health.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	emailSends = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "email_sends_total",
+		Help: "Email delivery attempts, by template and result. Magic link emails use the login template.",
+	}, []string{"template", "result"})
+)
+
+// unmatchedRoute labels requests that matched no route, so arbitrary paths
+// cannot blow up the number of series
+const unmatchedRoute = "unmatched"
+
+// HTTP records the count and latency of every request under its route
+// template, such as /inventory/:id, rather than the concrete path
+func HTTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// EmailSent counts a delivery attempt of an email rendered from template
+func EmailSent(template string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	emailSends.WithLabelValues(template, result).Inc()
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"be-test/apperror"
+	"be-test/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors renders the last error a handler or middleware reported with c.Error,
+// unless a response has already been written. Errors that are not an
+// apperror.Error are served as INTERNAL without their message.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		helpers.NewErrorResponse(c, apperror.From(c.Errors.Last().Err))
+	}
+}
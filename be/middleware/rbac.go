@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"be-test/apperror"
+	"be-test/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole only lets through users whose role is one of roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.MustGet("role").(models.Role)
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+
+		c.Error(apperror.PermissionDenied)
+		c.Abort()
+	}
+}
+
+// RequirePermission only lets through users whose role grants permission.
+// Requests made with an API key also need the permission among its scopes.
+// It must run after AuthMiddleware.
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.MustGet("role").(models.Role)
+		if !role.Can(permission) {
+			c.Error(apperror.PermissionDenied.WithFields(map[string]string{"permission": string(permission)}))
+			c.Abort()
+			return
+		}
+
+		if apiKey, ok := c.Get("api_key"); ok && !apiKey.(models.APIKey).Allows(permission) {
+			c.Error(apperror.ScopeMissing.WithFields(map[string]string{"scope": string(permission)}))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession only lets through requests signed in with an access token.
+// Requests made with an API key have no session to act on. It must run after
+// AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_key"); ok {
+			c.Error(apperror.SessionRequired)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
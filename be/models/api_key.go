@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// APIKey is a long-lived credential for machine clients such as the POS. It
+// acts as its owner user, limited to its scopes. Only the hash of the key is stored.
+type APIKey struct {
+	gorm.Model
+	OrganizationID uint                            `json:"-" gorm:"index"`
+	UserID         uint                            `json:"user_id" gorm:"index"`
+	Name           string                          `json:"name"`
+	Prefix         string                          `json:"prefix"`
+	KeyHash        string                          `json:"-" gorm:"uniqueIndex"`
+	Scopes         datatypes.JSONSlice[Permission] `json:"scopes"`
+	ExpiresAt      *time.Time                      `json:"expires_at"`
+	LastUsedAt     *time.Time                      `json:"last_used_at"`
+	RevokedAt      *time.Time                      `json:"revoked_at"`
+}
+
+// Active reports whether the key can still be used
+func (k APIKey) Active() bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt))
+}
+
+// Allows reports whether the key was granted permission
+func (k APIKey) Allows(permission Permission) bool {
+	for _, scope := range k.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
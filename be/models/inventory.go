@@ -1,12 +1,118 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
 
 // Inventory represents an inventory item
 type Inventory struct {
 	gorm.Model
-	ItemName    string  `json:"item_name"`
-	Quantity    float64 `json:"quantity"`
-	Uom         string  `json:"uom"`
-	PricePerQty float64 `json:"price_per_qty"`
+	OrganizationID uint    `json:"-" gorm:"index"`
+	ItemName       string  `json:"item_name"`
+	Quantity       float64 `json:"quantity"`
+	Uom            string  `json:"uom"`
+	PricePerQty    float64 `json:"price_per_qty"`
+	// ReorderLevel is the quantity at or below which the item is low on stock, 0 when not tracked
+	ReorderLevel float64 `json:"reorder_level"`
+	// Version counts the changes to the item and is served as its ETag
+	Version uint `json:"version" gorm:"not null;default:1"`
+}
+
+// InventoryInput is the part of an item clients may set. The ID, timestamps
+// and version are left out so a request body cannot overwrite them.
+type InventoryInput struct {
+	ItemName     string  `json:"item_name" binding:"required,notblank,max=100"`
+	Quantity     float64 `json:"quantity" binding:"positive"`
+	Uom          string  `json:"uom" binding:"required,unit"`
+	PricePerQty  float64 `json:"price_per_qty" binding:"positive"`
+	ReorderLevel float64 `json:"reorder_level" binding:"gte=0"`
+}
+
+// Inventory returns a new item holding the input
+func (i InventoryInput) Inventory() Inventory {
+	return Inventory{
+		ItemName:     i.ItemName,
+		Quantity:     i.Quantity,
+		Uom:          i.Uom,
+		PricePerQty:  i.PricePerQty,
+		ReorderLevel: i.ReorderLevel,
+	}
+}
+
+// unitOfMeasure describes a known unit: whether it counts pieces rather than
+// weight or volume, and how many of the smallest unit of its kind it holds
+type unitOfMeasure struct {
+	pieces bool
+	scale  float64
+}
+
+var units = map[string]unitOfMeasure{
+	"g":     {scale: 1},
+	"ml":    {scale: 1},
+	"kg":    {scale: 1000},
+	"liter": {scale: 1000},
+	"pcs":   {pieces: true, scale: 1},
+}
+
+// KnownUnit reports whether unit, in any case, is a unit of measure the API can price
+func KnownUnit(unit string) bool {
+	_, ok := units[strings.ToLower(unit)]
+	return ok
+}
+
+// Cost prices measurement of this item from what its stock cost: PricePerQty
+// is the price of the whole Quantity, in Uom. Items saved before units were
+// checked are taken to be stocked per kilogram, litre or piece. It returns 0
+// when the measurement's unit is unknown, counts pieces of stock bought by
+// weight or volume or the other way around, or the item has no quantity to
+// divide its price by.
+func (i Inventory) Cost(measurement Measurement) float64 {
+	stock := i.Uom
+	if !KnownUnit(stock) {
+		stock = "kg"
+		if units[strings.ToLower(measurement.Unit)].pieces {
+			stock = "pcs"
+		}
+	}
+
+	amount, ok := convert(measurement.Amount, measurement.Unit, stock)
+	if !ok || i.Quantity <= 0 {
+		return 0
+	}
+	return amount * i.PricePerQty / i.Quantity
+}
+
+// convert expresses amount of unit from in unit to. Weights and volumes convert
+// into each other like Cost prices them, pieces only into pieces. It returns
+// false when the units cannot be converted.
+func convert(amount float64, from string, to string) (float64, bool) {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	if from == to {
+		return amount, true
+	}
+	fromUnit, ok := units[from]
+	if !ok {
+		return 0, false
+	}
+	toUnit, ok := units[to]
+	if !ok || fromUnit.pieces != toUnit.pieces {
+		return 0, false
+	}
+	return amount * fromUnit.scale / toUnit.scale, true
+}
+
+// Absorb adds the stock of other to i, converted to i's unit of measure. The
+// prices are added too, as PricePerQty is what the stocked quantity cost, the
+// same meaning Cost gives it. It returns false, leaving i unchanged, when the
+// units cannot be combined.
+func (i *Inventory) Absorb(other Inventory) bool {
+	quantity, ok := convert(other.Quantity, other.Uom, i.Uom)
+	if !ok {
+		return false
+	}
+	i.Quantity += quantity
+	i.PricePerQty += other.PricePerQty
+	return true
 }
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invitation allows an email to join an organization with a given role
+type Invitation struct {
+	gorm.Model
+	OrganizationID uint       `json:"-" gorm:"index"`
+	Email          string     `json:"email" binding:"required,email" gorm:"index"`
+	Role           Role       `json:"role" binding:"required" gorm:"size:20"`
+	InvitedByID    uint       `json:"invited_by_id"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+}
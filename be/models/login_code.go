@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginCode is a one-time numeric code emailed alongside the magic link, for
+// signing in on a device other than the one that opens the email. Only the
+// hash of the code is stored.
+type LoginCode struct {
+	gorm.Model
+	Email            string `gorm:"index"`
+	CodeHash         string
+	Attempts         int
+	ExpiresAt        time.Time
+	ConsumedAt       *time.Time
+	RequestIP        string
+	RequestUserAgent string
+}
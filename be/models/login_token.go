@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginToken is a single-use magic link. Only the SHA-256 hash of the token
+// sent by email is stored.
+type LoginToken struct {
+	gorm.Model
+	Email            string `gorm:"index"`
+	TokenHash        string `gorm:"uniqueIndex"`
+	ExpiresAt        time.Time
+	ConsumedAt       *time.Time
+	RequestIP        string
+	RequestUserAgent string
+}
@@ -0,0 +1,9 @@
+package models
+
+import "gorm.io/gorm"
+
+// Organization represents a coffee brand (tenant) that owns users, inventory and recipes
+type Organization struct {
+	gorm.Model
+	Name string `json:"name"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Delivery states of an OutboundEmail
+const (
+	EmailPending = "pending"
+	EmailSending = "sending"
+	EmailSent    = "sent"
+	EmailDead    = "dead"
+)
+
+// OutboundEmail is a rendered email waiting in the outbox for the delivery worker
+type OutboundEmail struct {
+	gorm.Model
+	// OrganizationID is the organization the email was sent for, nil for sign-ups
+	OrganizationID *uint      `json:"-" gorm:"index"`
+	Template       string     `json:"template" gorm:"size:50"`
+	ToAddress      string     `json:"to_address" gorm:"index"`
+	Subject        string     `json:"subject"`
+	HTML           string     `json:"-"`
+	Text           string     `json:"-"`
+	Status         string     `json:"status" gorm:"size:20;index"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastError      string     `json:"last_error"`
+	SentAt         *time.Time `json:"sent_at"`
+}
@@ -1,24 +1,102 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
+
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 type Recipe struct {
 	gorm.Model
-	SKU          string         `json:"sku"`
-	NumberOfCups int            `json:"number_of_cups"`
-	Ingredients  datatypes.JSON `json:"ingredients"` // Using GORM's datatypes.JSON
-	COGS         float64        `json:"cogs"`
+	OrganizationID uint           `json:"-" gorm:"index"`
+	SKU            string         `json:"sku"`
+	NumberOfCups   int            `json:"number_of_cups"`
+	Ingredients    datatypes.JSON `json:"ingredients"` // Using GORM's datatypes.JSON
+	COGS           float64        `json:"cogs"`
+	// Version counts the changes to the recipe and is served as its ETag
+	Version uint `json:"version" gorm:"not null;default:1"`
 }
 
+// ErrUnitMismatch is returned when two amounts of an ingredient cannot be added up
+var ErrUnitMismatch = errors.New("ingredient amounts are in units that cannot be added up")
+
 type Measurement struct {
-	Amount float64 `json:"amount"`
-	Unit   string  `json:"unit"` // g, ml, pcs
+	Amount float64 `json:"amount" binding:"positive"`
+	Unit   string  `json:"unit" binding:"required,unit"` // g, ml, kg, liter, pcs
 }
 
 type RecipeInput struct {
-	NumberOfCups int                    `json:"number_of_cups"`
-	Ingredients  map[string]Measurement `json:"ingredients"`
+	NumberOfCups int `json:"number_of_cups" binding:"required,min=1"`
+	// Ingredients maps inventory item names to the amount used per cup
+	Ingredients map[string]Measurement `json:"ingredients" binding:"required,min=1,dive,keys,notblank,inventory_item,endkeys,required"`
+}
+
+// IngredientNames returns the inventory item names the recipe uses
+func (r RecipeInput) IngredientNames() []string {
+	names := make([]string, 0, len(r.Ingredients))
+	for name := range r.Ingredients {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Plus returns m with other added, converted to m's unit. It returns false when
+// the units cannot be added up, such as pieces and grams.
+func (m Measurement) Plus(other Measurement) (Measurement, bool) {
+	amount, ok := convert(other.Amount, other.Unit, m.Unit)
+	if !ok {
+		return m, false
+	}
+	m.Amount += amount
+	return m, true
+}
+
+// RenameIngredient makes the recipe use the inventory item into wherever it
+// uses from, both matched in any case. When the recipe already uses into the
+// amounts are added up. It reports whether the recipe used from.
+func (r *Recipe) RenameIngredient(from string, into string) (bool, error) {
+	var ingredients map[string]Measurement
+	if err := json.Unmarshal(r.Ingredients, &ingredients); err != nil {
+		return false, err
+	}
+
+	var uses, used []string
+	for name := range ingredients {
+		switch {
+		case strings.EqualFold(name, into):
+			uses = append([]string{name}, uses...)
+		case strings.EqualFold(name, from):
+			uses = append(uses, name)
+		}
+		// from and into may differ only in case
+		if strings.EqualFold(name, from) {
+			used = append(used, name)
+		}
+	}
+	if len(used) == 0 {
+		return false, nil
+	}
+
+	// Add up in the unit the recipe already uses into in, if it does
+	total := ingredients[uses[0]]
+	for _, name := range uses[1:] {
+		var ok bool
+		if total, ok = total.Plus(ingredients[name]); !ok {
+			return false, ErrUnitMismatch
+		}
+	}
+	for _, name := range uses {
+		delete(ingredients, name)
+	}
+	ingredients[into] = total
+
+	data, err := json.Marshal(ingredients)
+	if err != nil {
+		return false, err
+	}
+	r.Ingredients = data
+	return true, nil
 }
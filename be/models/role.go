@@ -0,0 +1,63 @@
+package models
+
+// Role is the access level of a user within their organization
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleManager Role = "manager"
+	RoleBarista Role = "barista"
+	RoleViewer  Role = "viewer"
+)
+
+// Permission is a single action that can be granted to a role
+type Permission string
+
+const (
+	PermissionInventoryRead  Permission = "inventory:read"
+	PermissionInventoryWrite Permission = "inventory:write"
+	PermissionInventoryMerge Permission = "inventory:merge"
+	PermissionRecipeRead     Permission = "recipe:read"
+	PermissionRecipeBrew     Permission = "recipe:brew"
+	PermissionUsersManage    Permission = "users:manage"
+	PermissionAPIKeysManage  Permission = "api_keys:manage"
+	PermissionEmailsManage   Permission = "emails:manage"
+)
+
+// rolePermissions lists what each role may do. Inventory writes include
+// prices, so baristas can brew recipes but cannot change what stock costs.
+var rolePermissions = map[Role][]Permission{
+	RoleOwner: {
+		PermissionInventoryRead, PermissionInventoryWrite, PermissionInventoryMerge,
+		PermissionRecipeRead, PermissionRecipeBrew,
+		PermissionUsersManage, PermissionAPIKeysManage, PermissionEmailsManage,
+	},
+	RoleManager: {
+		PermissionInventoryRead, PermissionInventoryWrite,
+		PermissionRecipeRead, PermissionRecipeBrew,
+	},
+	RoleBarista: {
+		PermissionInventoryRead,
+		PermissionRecipeRead, PermissionRecipeBrew,
+	},
+	RoleViewer: {
+		PermissionInventoryRead,
+		PermissionRecipeRead,
+	},
+}
+
+// Valid reports whether r is one of the known roles
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether r has been granted permission
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
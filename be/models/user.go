@@ -1,10 +1,18 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // User represents a user in the system
 type User struct {
 	gorm.Model
-	Email       string `json:"email"  binding:"required,email"`
-	AccessToken string `json:"access_token"`
+	OrganizationID uint       `json:"-" gorm:"index"`
+	Email          string     `json:"email"  binding:"required,email" gorm:"uniqueIndex"`
+	Role           Role       `json:"role" gorm:"size:20"`
+	DeactivatedAt  *time.Time `json:"deactivated_at"`
+	// Locale is the language API messages are sent in, empty to follow Accept-Language
+	Locale string `json:"locale" gorm:"size:10"`
 }
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"be-test/models"
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// InventoryRepository stores the inventory items of the caller's organization
+type InventoryRepository interface {
+	// List returns a page of items whose name contains opts.Search, and the total number of matches
+	List(ctx context.Context, opts ListOptions) ([]models.Inventory, int64, error)
+	Get(ctx context.Context, id uint) (models.Inventory, error)
+	Create(ctx context.Context, item *models.Inventory) error
+	// Save updates item and bumps its version, or returns ErrStale when the item changed since it was loaded
+	Save(ctx context.Context, item *models.Inventory) error
+	// Delete deletes item, or returns ErrStale when it changed since it was loaded
+	Delete(ctx context.Context, item *models.Inventory) error
+	// FindByNames returns the items with the given names in any case, in no particular order
+	FindByNames(ctx context.Context, names []string) ([]models.Inventory, error)
+	// Merge saves into, deletes from and saves the recipes that were rewritten to use into, all or
+	// nothing. It returns ErrStale when any of them changed since they were loaded.
+	Merge(ctx context.Context, from *models.Inventory, into *models.Inventory, recipes []models.Recipe) error
+}
+
+type inventoryRepository struct {
+	db *gorm.DB
+}
+
+// NewInventoryRepository returns an InventoryRepository backed by db
+func NewInventoryRepository(db *gorm.DB) InventoryRepository {
+	return &inventoryRepository{db: db}
+}
+
+func (r *inventoryRepository) List(ctx context.Context, opts ListOptions) ([]models.Inventory, int64, error) {
+	var inventory []models.Inventory
+	var total int64
+
+	query := containsFold(r.db.WithContext(ctx).Model(&models.Inventory{}), "item_name", opts.Search)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Offset(opts.Offset).Limit(opts.Limit).Find(&inventory).Error
+	return inventory, total, err
+}
+
+func (r *inventoryRepository) Get(ctx context.Context, id uint) (models.Inventory, error) {
+	var item models.Inventory
+	err := r.db.WithContext(ctx).First(&item, id).Error
+	return item, err
+}
+
+func (r *inventoryRepository) Create(ctx context.Context, item *models.Inventory) error {
+	item.Version = 1
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+func (r *inventoryRepository) Save(ctx context.Context, item *models.Inventory) error {
+	return saveVersion(r.db.WithContext(ctx), item, &item.Version)
+}
+
+func (r *inventoryRepository) Delete(ctx context.Context, item *models.Inventory) error {
+	return deleteVersion(r.db.WithContext(ctx), item, item.Version)
+}
+
+func (r *inventoryRepository) FindByNames(ctx context.Context, names []string) ([]models.Inventory, error) {
+	folded := make([]string, len(names))
+	for i, name := range names {
+		folded[i] = strings.ToLower(name)
+	}
+
+	var items []models.Inventory
+	err := r.db.WithContext(ctx).Where("LOWER(item_name) IN ?", folded).Find(&items).Error
+	return items, err
+}
+
+func (r *inventoryRepository) Merge(ctx context.Context, from *models.Inventory, into *models.Inventory, recipes []models.Recipe) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Delete first so the names no longer clash when they differ only in case
+		if err := deleteVersion(tx, from, from.Version); err != nil {
+			return err
+		}
+		if err := saveVersion(tx, into, &into.Version); err != nil {
+			return err
+		}
+		for i := range recipes {
+			if err := saveVersion(tx, &recipes[i], &recipes[i].Version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
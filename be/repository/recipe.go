@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"be-test/models"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// RecipeRepository stores the recipes of the caller's organization
+type RecipeRepository interface {
+	// List returns a page of recipes whose SKU contains opts.Search, newest first, and the total number of matches
+	List(ctx context.Context, opts ListOptions) ([]models.Recipe, int64, error)
+	Get(ctx context.Context, id uint) (models.Recipe, error)
+	// Latest returns the most recently created recipe
+	Latest(ctx context.Context) (models.Recipe, error)
+	Create(ctx context.Context, recipe *models.Recipe) error
+	// Save updates recipe and bumps its version, or returns ErrStale when the recipe changed since it was loaded
+	Save(ctx context.Context, recipe *models.Recipe) error
+	// UsingIngredient returns the recipes that use the inventory item name, in any case
+	UsingIngredient(ctx context.Context, name string) ([]models.Recipe, error)
+}
+
+type recipeRepository struct {
+	db *gorm.DB
+}
+
+// NewRecipeRepository returns a RecipeRepository backed by db
+func NewRecipeRepository(db *gorm.DB) RecipeRepository {
+	return &recipeRepository{db: db}
+}
+
+func (r *recipeRepository) List(ctx context.Context, opts ListOptions) ([]models.Recipe, int64, error) {
+	var recipes []models.Recipe
+	var total int64
+
+	query := containsFold(r.db.WithContext(ctx).Model(&models.Recipe{}), "sku", opts.Search)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Offset(opts.Offset).Limit(opts.Limit).Order("id desc").Find(&recipes).Error
+	return recipes, total, err
+}
+
+func (r *recipeRepository) Get(ctx context.Context, id uint) (models.Recipe, error) {
+	var recipe models.Recipe
+	err := r.db.WithContext(ctx).First(&recipe, id).Error
+	return recipe, err
+}
+
+func (r *recipeRepository) Latest(ctx context.Context) (models.Recipe, error) {
+	var recipe models.Recipe
+	err := r.db.WithContext(ctx).Order("created_at desc").First(&recipe).Error
+	return recipe, err
+}
+
+func (r *recipeRepository) Create(ctx context.Context, recipe *models.Recipe) error {
+	recipe.Version = 1
+	return r.db.WithContext(ctx).Create(recipe).Error
+}
+
+func (r *recipeRepository) Save(ctx context.Context, recipe *models.Recipe) error {
+	return saveVersion(r.db.WithContext(ctx), recipe, &recipe.Version)
+}
+
+func (r *recipeRepository) UsingIngredient(ctx context.Context, name string) ([]models.Recipe, error) {
+	// Ingredients are JSON, which Postgres and SQLite query differently, so
+	// the names are matched here instead
+	var matches []models.Recipe
+	var batch []models.Recipe
+	err := r.db.WithContext(ctx).FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, recipe := range batch {
+			if len(recipe.Ingredients) == 0 {
+				continue
+			}
+			var ingredients map[string]json.RawMessage
+			if err := json.Unmarshal(recipe.Ingredients, &ingredients); err != nil {
+				return err
+			}
+			for ingredient := range ingredients {
+				if strings.EqualFold(ingredient, name) {
+					matches = append(matches, recipe)
+					break
+				}
+			}
+		}
+		return nil
+	}).Error
+	return matches, err
+}
@@ -0,0 +1,68 @@
+// Package repository stores and loads the API's models. Each repository is
+// an interface with a GORM implementation, so handlers can be tested against
+// in-memory fakes. Tenant scoping comes from the context passed to every
+// method, see database.WithTenant.
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when no row matches. It is GORM's error so the API
+// response helper answers 404 for both the GORM repositories and fakes.
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrDuplicate is returned when a row would break a unique index, such as a
+// second active inventory item with the same name
+var ErrDuplicate = gorm.ErrDuplicatedKey
+
+// ErrStale is returned when a row changed since it was loaded: its version no
+// longer matches the one the caller saves or deletes
+var ErrStale = errors.New("row has changed since it was loaded")
+
+// ListOptions selects a page of rows, optionally only those matching Search
+type ListOptions struct {
+	Offset int
+	Limit  int
+	Search string
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// containsFold filters query to rows where column contains search, ignoring
+// case. LOWER and LIKE with an explicit escape behave alike on Postgres and
+// SQLite, unlike ILIKE.
+func containsFold(query *gorm.DB, column string, search string) *gorm.DB {
+	if search == "" {
+		return query
+	}
+	return query.Where("LOWER("+column+") LIKE ? ESCAPE '\\'", "%"+likeEscaper.Replace(strings.ToLower(search))+"%")
+}
+
+// saveVersion updates every column of row, which has the version *version, and
+// bumps *version. It returns ErrStale when another save bumped it first.
+func saveVersion(db *gorm.DB, row interface{}, version *uint) error {
+	loaded := *version
+	*version++
+	result := db.Model(row).Where("version = ?", loaded).Select("*").Updates(row)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrStale
+	}
+	if result.Error != nil {
+		*version = loaded
+	}
+	return result.Error
+}
+
+// deleteVersion deletes row when it still has version, and returns ErrStale when not
+func deleteVersion(db *gorm.DB, row interface{}, version uint) error {
+	result := db.Where("version = ?", version).Delete(row)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrStale
+	}
+	return result.Error
+}
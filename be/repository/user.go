@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"be-test/database"
+	"be-test/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserRepository stores the users of the caller's organization
+type UserRepository interface {
+	// List returns a page of users whose email contains opts.Search, oldest first, and the total number of matches
+	List(ctx context.Context, opts ListOptions) ([]models.User, int64, error)
+	Get(ctx context.Context, id uint) (models.User, error)
+	// FindByEmail looks up a user in any organization, because an email can only belong to one
+	FindByEmail(ctx context.Context, email string) (models.User, error)
+	// FindByID looks up a user in any organization, for callers such as a session refresh that have none yet
+	FindByID(ctx context.Context, id uint) (models.User, error)
+	UpdateRole(ctx context.Context, user *models.User, role models.Role) error
+	// SetDeactivatedAt deactivates user at the given time, or reactivates them when it is nil
+	SetDeactivatedAt(ctx context.Context, user *models.User, deactivatedAt *time.Time) error
+	// UpdateLocale sets the language user wants API messages in, empty to follow Accept-Language
+	UpdateLocale(ctx context.Context, user *models.User, locale string) error
+}
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository returns a UserRepository backed by db
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+func (r *userRepository) List(ctx context.Context, opts ListOptions) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	query := containsFold(r.db.WithContext(ctx).Model(&models.User{}), "email", opts.Search)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Offset(opts.Offset).Limit(opts.Limit).Order("id").Find(&users).Error
+	return users, total, err
+}
+
+func (r *userRepository) Get(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).First(&user, id).Error
+	return user, err
+}
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	var user models.User
+	err := database.WithoutTenant(r.db.WithContext(ctx)).Where("email = ?", email).First(&user).Error
+	return user, err
+}
+
+func (r *userRepository) FindByID(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := database.WithoutTenant(r.db.WithContext(ctx)).First(&user, id).Error
+	return user, err
+}
+
+func (r *userRepository) UpdateRole(ctx context.Context, user *models.User, role models.Role) error {
+	return r.db.WithContext(ctx).Model(user).Update("role", role).Error
+}
+
+func (r *userRepository) SetDeactivatedAt(ctx context.Context, user *models.User, deactivatedAt *time.Time) error {
+	return r.db.WithContext(ctx).Model(user).Update("deactivated_at", deactivatedAt).Error
+}
+
+func (r *userRepository) UpdateLocale(ctx context.Context, user *models.User, locale string) error {
+	return r.db.WithContext(ctx).Model(user).Update("locale", locale).Error
+}
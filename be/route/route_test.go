@@ -0,0 +1,51 @@
+package route
+
+import (
+	handler "be-test/handlers"
+	"be-test/openapi"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOpenAPISpecMatchesRoutes fails when a route is added or removed without
+// updating handler.OpenAPISpec, or the other way around
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	SetupRoutes(router, &handler.Handler{}, func(c *gin.Context) {})
+
+	var routes []string
+	for _, r := range router.Routes() {
+		routes = append(routes, r.Method+" "+openapi.Path(r.Path))
+	}
+	sort.Strings(routes)
+
+	var documented []string
+	for path, operations := range handler.OpenAPISpec().Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(documented)
+
+	assert.Equal(t, routes, documented)
+}
+
+// TestSetupRoutesRegistersValidation fails when a router can serve the API
+// without the binding rules its handlers rely on
+func TestSetupRoutesRegistersValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetupRoutes(gin.New(), &handler.Handler{}, func(c *gin.Context) {})
+
+	body := struct {
+		UOM string `json:"uom" binding:"unit"`
+	}{UOM: "kg"}
+	assert.NotPanics(t, func() {
+		assert.NoError(t, binding.Validator.ValidateStruct(body))
+	})
+}
@@ -1,39 +0,0 @@
--- Users table
-CREATE TABLE users (
-    id SERIAL PRIMARY KEY,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    deleted_at TIMESTAMP WITH TIME ZONE,
-    email VARCHAR(255) NOT NULL,
-    access_token TEXT
-);
-
--- Inventory table
-CREATE TABLE inventories (
-    id SERIAL PRIMARY KEY,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    deleted_at TIMESTAMP WITH TIME ZONE,
-    item_name VARCHAR(255) NOT NULL,
-    quantity DECIMAL(10,2) NOT NULL,
-    uom VARCHAR(50) NOT NULL,
-    price_per_qty DECIMAL(10,2) NOT NULL
-);
-
--- Recipes table
-CREATE TABLE recipes (
-    id SERIAL PRIMARY KEY,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    deleted_at TIMESTAMP WITH TIME ZONE,
-    sku VARCHAR(255) NOT NULL,
-    number_of_cups INTEGER NOT NULL,
-    ingredients JSONB NOT NULL,
-    cogs DECIMAL(10,2) NOT NULL
-);
-
--- Indexes
-CREATE INDEX idx_users_email ON users(email);
-CREATE INDEX idx_users_access_token ON users(access_token);
-CREATE INDEX idx_recipes_sku ON recipes(sku);
-CREATE INDEX idx_inventory_item_name ON inventories(item_name);
@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"be-test/database"
+	"be-test/metrics"
+	"be-test/models"
+	"be-test/tracing"
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// Emails of these templates carry sign-in secrets that expire within minutes.
+// Their bodies are cleared once they are dead as well as once they are sent,
+// and they are never retried.
+var secretTemplates = map[string]bool{"login": true}
+
+// Retryable reports whether a failed email of template may be queued again
+func Retryable(template string) bool {
+	return !secretTemplates[template]
+}
+
+// EnqueueTemplate renders the named template in locale and stores it in the
+// outbox of organizationID, to be delivered by an EmailWorker. organizationID
+// is 0 for emails sent for no organization, such as a sign-up.
+func EnqueueTemplate(db *gorm.DB, organizationID uint, locale string, name string, to string, data interface{}) error {
+	msg, err := RenderEmail(locale, name, data)
+	if err != nil {
+		return err
+	}
+
+	email := models.OutboundEmail{
+		Template:      name,
+		ToAddress:     to,
+		Subject:       msg.Subject,
+		HTML:          msg.HTML,
+		Text:          msg.Text,
+		Status:        models.EmailPending,
+		NextAttemptAt: time.Now(),
+	}
+	if organizationID != 0 {
+		email.OrganizationID = &organizationID
+	}
+	return database.WithoutTenant(db).Create(&email).Error
+}
+
+// EmailWorker delivers outbox emails, retrying failures with exponential
+// backoff until MaxAttempts, after which the email is marked dead
+type EmailWorker struct {
+	DB           *gorm.DB
+	Mailer       Mailer
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+
+	// Lease is how long a claimed email is reserved for one worker. An email
+	// left in sending by a crashed worker is picked up again once it expires.
+	Lease time.Duration
+}
+
+// NewEmailWorker creates an EmailWorker with the default schedule
+func NewEmailWorker(db *gorm.DB, mailer Mailer) *EmailWorker {
+	return &EmailWorker{
+		DB:           db,
+		Mailer:       mailer,
+		PollInterval: time.Second * 5,
+		BatchSize:    10,
+		MaxAttempts:  8,
+		BaseBackoff:  time.Second * 30,
+		MaxBackoff:   time.Hour,
+		Lease:        time.Minute * 5,
+	}
+}
+
+// Run delivers due emails every PollInterval until ctx is cancelled. A batch
+// already being delivered is finished first, so no email is left claimed.
+func (w *EmailWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.ProcessDue(context.WithoutCancel(ctx)); err != nil {
+			slog.Error("email delivery failed", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue delivers the emails that are due now and returns how it went
+// with the outbox itself; delivery failures are recorded on each email
+func (w *EmailWorker) ProcessDue(ctx context.Context) error {
+	// The outbox holds the emails of every organization
+	db := database.WithoutTenant(w.DB.WithContext(ctx))
+
+	var due []models.OutboundEmail
+	err := db.Where("status IN ? AND next_attempt_at <= ?", []string{models.EmailPending, models.EmailSending}, time.Now()).
+		Order("next_attempt_at").Limit(w.BatchSize).Find(&due).Error
+	if err != nil {
+		return err
+	}
+
+	for _, email := range due {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		claimed, err := w.claim(db, email)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		if err := w.deliver(ctx, db, email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claim reserves email for this worker. The conditional update makes sure
+// only one of several workers sends it.
+func (w *EmailWorker) claim(db *gorm.DB, email models.OutboundEmail) (bool, error) {
+	now := time.Now()
+	result := db.Model(&models.OutboundEmail{}).
+		Where("id = ? AND status IN ? AND next_attempt_at <= ?", email.ID, []string{models.EmailPending, models.EmailSending}, now).
+		Updates(map[string]interface{}{
+			"status":          models.EmailSending,
+			"next_attempt_at": now.Add(w.Lease),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (w *EmailWorker) deliver(ctx context.Context, db *gorm.DB, email models.OutboundEmail) error {
+	sendCtx, span := tracing.Tracer().Start(ctx, "email.send", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.Int("email.id", int(email.ID)),
+		attribute.String("email.template", email.Template),
+		attribute.Int("email.attempt", email.Attempts+1),
+	))
+	sendErr := w.Mailer.Send(sendCtx, Message{
+		To:      email.ToAddress,
+		Subject: email.Subject,
+		HTML:    email.HTML,
+		Text:    email.Text,
+	})
+	tracing.End(span, sendErr)
+
+	metrics.EmailSent(email.Template, sendErr)
+
+	// A sent email's body is no longer needed, and may hold a sign-in link or code
+	attempts := email.Attempts + 1
+	if sendErr == nil {
+		return db.Model(&email).Updates(map[string]interface{}{
+			"status":     models.EmailSent,
+			"attempts":   attempts,
+			"sent_at":    time.Now(),
+			"last_error": "",
+			"html":       "",
+			"text":       "",
+		}).Error
+	}
+
+	slog.WarnContext(ctx, "email send failed", "email_id", email.ID, "attempts", attempts, "error", sendErr.Error())
+	updates := map[string]interface{}{
+		"status":          models.EmailPending,
+		"attempts":        attempts,
+		"next_attempt_at": time.Now().Add(w.backoff(attempts)),
+		"last_error":      sendErr.Error(),
+	}
+	if attempts >= w.MaxAttempts {
+		updates["status"] = models.EmailDead
+		if secretTemplates[email.Template] {
+			updates["html"] = ""
+			updates["text"] = ""
+		}
+	}
+	return db.Model(&email).Updates(updates).Error
+}
+
+// backoff doubles the wait after every failed attempt, up to MaxBackoff
+func (w *EmailWorker) backoff(attempts int) time.Duration {
+	wait := w.BaseBackoff
+	for i := 1; i < attempts && wait < w.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > w.MaxBackoff {
+		wait = w.MaxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"be-test/config"
+	"context"
+)
+
+// Message is a rendered email with an HTML body and a plain-text alternative
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Pinger is implemented by mailers that can check their transport is
+// reachable without sending anything
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// NewMailer returns the transport selected by cfg. The outbox transport writes
+// messages to a directory instead of sending them, for local runs.
+func NewMailer(cfg config.Mail) Mailer {
+	if cfg.Transport == "outbox" {
+		return NewOutboxMailer(cfg.OutboxDir)
+	}
+	return NewSMTPMailer(SMTPConfig{
+		Host:       cfg.SMTPHost,
+		Port:       cfg.SMTPPort,
+		Username:   cfg.SMTPEmail,
+		Password:   cfg.SMTPPassword,
+		SenderName: cfg.SMTPSenderName,
+	})
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// OutboxMailer keeps messages instead of sending them, for local runs and tests.
+// With a directory set, every message is also written there as an .eml file
+// that any mail client can open.
+type OutboxMailer struct {
+	dir      string
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewOutboxMailer creates an OutboxMailer writing to dir, or only keeping
+// messages in memory when dir is empty
+func NewOutboxMailer(dir string) *OutboxMailer {
+	return &OutboxMailer{dir: dir}
+}
+
+// Send stores msg in the outbox
+func (m *OutboxMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.dir != "" {
+		if err := os.MkdirAll(m.dir, 0755); err != nil {
+			return err
+		}
+
+		message := gomail.NewMessage()
+		message.SetHeader("To", msg.To)
+		message.SetHeader("Subject", msg.Subject)
+		message.SetBody("text/plain", msg.Text)
+		message.AddAlternative("text/html", msg.HTML)
+
+		name := fmt.Sprintf("%s-%03d.eml", time.Now().UTC().Format("20060102T150405.000000000"), len(m.messages))
+		file, err := os.Create(filepath.Join(m.dir, name))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if _, err := message.WriteTo(file); err != nil {
+			return err
+		}
+	}
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Ping checks that the outbox directory can be created, when one is set
+func (m *OutboxMailer) Ping(ctx context.Context) error {
+	if m.dir == "" {
+		return ctx.Err()
+	}
+	return os.MkdirAll(m.dir, 0755)
+}
+
+// Messages returns every message sent so far, oldest first
+func (m *OutboxMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.messages...)
+}
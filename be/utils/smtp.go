@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"strconv"
+
+	"gopkg.in/gomail.v2"
+)
+
+// SMTPConfig holds the SMTP server and sender settings
+type SMTPConfig struct {
+	Host       string
+	Port       int
+	Username   string
+	Password   string
+	SenderName string
+}
+
+// SMTPMailer sends messages through an SMTP server, verifying its TLS certificate
+type SMTPMailer struct {
+	config SMTPConfig
+	dialer *gomail.Dialer
+}
+
+// NewSMTPMailer creates an SMTPMailer for config
+func NewSMTPMailer(config SMTPConfig) *SMTPMailer {
+	dialer := gomail.NewDialer(config.Host, config.Port, config.Username, config.Password)
+	dialer.TLSConfig = &tls.Config{ServerName: config.Host, MinVersion: tls.VersionTLS12}
+
+	return &SMTPMailer{config: config, dialer: dialer}
+}
+
+// Send delivers msg. gomail does not take a context, so ctx is only checked before dialing.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	from := (&mail.Address{Name: m.config.SenderName, Address: m.config.Username}).String()
+
+	message := gomail.NewMessage()
+	message.SetHeader("From", from)
+	message.SetHeader("To", msg.To)
+	message.SetHeader("Subject", msg.Subject)
+	message.SetBody("text/plain", msg.Text)
+	message.AddAlternative("text/html", msg.HTML)
+
+	return m.dialer.DialAndSend(message)
+}
+
+// Ping checks that the SMTP server accepts connections. It does not log in,
+// so bad credentials only show up when sending.
+func (m *SMTPMailer) Ping(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
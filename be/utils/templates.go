@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"be-test/i18n"
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// DefaultLocale is used when no requested locale has templates
+const DefaultLocale = i18n.DefaultLocale
+
+// MatchLocale picks the supported locale that best fits an Accept-Language header.
+// Emails support the same locales as the API messages.
+func MatchLocale(acceptLanguage string) string {
+	return i18n.Match(acceptLanguage)
+}
+
+// RenderEmail renders the subject, HTML and plain-text templates of name in
+// locale, falling back to DefaultLocale when the locale has no such template
+func RenderEmail(locale string, name string, data interface{}) (Message, error) {
+	if _, err := fs.Stat(templateFS, "templates/"+locale+"/"+name+".html"); err != nil {
+		locale = DefaultLocale
+	}
+	prefix := "templates/" + locale + "/" + name
+
+	subject, err := renderText(prefix+".subject.txt", data)
+	if err != nil {
+		return Message{}, err
+	}
+	text, err := renderText(prefix+".txt", data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	html, err := htmltemplate.ParseFS(templateFS, prefix+".html")
+	if err != nil {
+		return Message{}, err
+	}
+	var htmlBody bytes.Buffer
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject),
+		HTML:    htmlBody.String(),
+		Text:    text,
+	}, nil
+}
+
+func renderText(path string, data interface{}) (string, error) {
+	tmpl, err := texttemplate.ParseFS(templateFS, path)
+	if err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
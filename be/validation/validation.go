@@ -0,0 +1,91 @@
+// Package validation adds the API's own binding rules to gin's validator:
+//
+//	notblank        the string has a character other than whitespace
+//	positive        the number is greater than zero
+//	unit            the string is a known unit of measure, see models.KnownUnit
+//	inventory_item  the string names an item in the caller's inventory, in any case
+//
+// Fields are reported by their JSON names.
+package validation
+
+import (
+	"be-test/models"
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+)
+
+var registerOnce sync.Once
+
+// Register installs the rules on gin's validator. It is safe to call more than once.
+func Register() {
+	registerOnce.Do(func() {
+		validate := engine()
+		validate.RegisterTagNameFunc(jsonName)
+		validate.RegisterValidation("notblank", validators.NotBlank)
+		validate.RegisterValidation("positive", positive)
+		validate.RegisterValidation("unit", unit)
+		validate.RegisterValidationCtx("inventory_item", inventoryItem)
+	})
+}
+
+func engine() *validator.Validate {
+	return binding.Validator.Engine().(*validator.Validate)
+}
+
+// jsonName names a field after its JSON key, so errors use the names clients send
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+func positive(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int() > 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return field.Uint() > 0
+	case reflect.Float32, reflect.Float64:
+		return field.Float() > 0
+	}
+	return false
+}
+
+func unit(fl validator.FieldLevel) bool {
+	return models.KnownUnit(fl.Field().String())
+}
+
+type inventoryKey struct{}
+
+// WithInventory lets the inventory_item rule accept the names of items, in
+// any case. Without it the rule passes, since gin's binding has no request context.
+func WithInventory(ctx context.Context, items []models.Inventory) context.Context {
+	names := make(map[string]bool, len(items))
+	for _, item := range items {
+		names[strings.ToLower(item.ItemName)] = true
+	}
+	return context.WithValue(ctx, inventoryKey{}, names)
+}
+
+func inventoryItem(ctx context.Context, fl validator.FieldLevel) bool {
+	names, ok := ctx.Value(inventoryKey{}).(map[string]bool)
+	return !ok || names[strings.ToLower(fl.Field().String())]
+}
+
+// Struct checks v, which gin has already bound, again with the rules that
+// need ctx, such as inventory_item with the items of WithInventory
+func Struct(ctx context.Context, v interface{}) error {
+	return engine().StructCtx(ctx, v)
+}